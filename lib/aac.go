@@ -0,0 +1,209 @@
+package mp4
+
+const (
+  AAC_AOT_MAIN = 1
+  AAC_AOT_LC = 2
+  AAC_AOT_SSR = 3
+  AAC_AOT_LTP = 4
+  AAC_AOT_SBR = 5
+  AAC_AOT_ER_AAC_LD = 23
+  AAC_AOT_PS = 29
+)
+
+var aacSampleRates = []uint32{
+  96000, 88200, 64000, 48000, 44100, 32000, 24000, 22050, 16000, 12000, 11025, 8000, 7350,
+};
+
+var aacChannels = []uint8{0, 1, 2, 3, 4, 5, 6, 8, 0, 0, 0, 7, 8, 24, 8};
+
+type AudioSpecificConfig struct {
+  ObjectType uint8 `json:"objectType"`
+  SamplingFrequencyIndex uint8 `json:"samplingFrequencyIndex"`
+  SamplingFrequency uint32 `json:"samplingFrequency"`
+  ChannelConfiguration uint8 `json:"channelConfiguration"`
+  ExtensionObjectType uint8 `json:"extensionObjectType"`
+  ExtensionSamplingFrequency uint32 `json:"extensionSamplingFrequency"`
+  SBR bool `json:"sbr"`
+  PS bool `json:"ps"`
+  // set when SBR/PS was found through the backward compatible sync
+  // extension rather than the leading object type
+  BackwardCompatible bool `json:"backwardCompatible"`
+  FrameLength int `json:"frameLength"`
+  DependsOnCoreCoder bool `json:"dependsOnCoreCoder"`
+  CoreCoderDelay uint16 `json:"coreCoderDelay"`
+}
+
+func readAudioObjectType(br *BitReader) uint8 {
+  aot := uint8(br.ReadBits(5));
+
+  if (aot == 31) {
+    aot = 32 + uint8(br.ReadBits(6));
+  }
+
+  return aot;
+}
+
+func readSamplingFrequency(br *BitReader) (uint8, uint32) {
+  idx := uint8(br.ReadBits(4));
+
+  if (idx == 0x0f) {
+    return idx, uint32(br.ReadBits(24));
+  }
+
+  if (int(idx) < len(aacSampleRates)) {
+    return idx, aacSampleRates[idx];
+  }
+
+  return idx, 0;
+}
+
+// ParseAudioSpecificConfig decodes the AudioSpecificConfig carried in the
+// DecoderSpecificInfo of MPEG-4 audio streams (ISO/IEC 14496-3 1.6.2.1).
+func ParseAudioSpecificConfig(data []byte) (*AudioSpecificConfig, error) {
+  asc := AudioSpecificConfig{};
+  br := NewBitReader(data);
+
+  asc.ObjectType = readAudioObjectType(br);
+  asc.SamplingFrequencyIndex,asc.SamplingFrequency = readSamplingFrequency(br);
+  asc.ChannelConfiguration = uint8(br.ReadBits(4));
+
+  if (asc.ObjectType == AAC_AOT_SBR || asc.ObjectType == AAC_AOT_PS) {
+    asc.ExtensionObjectType = AAC_AOT_SBR;
+    asc.SBR = true;
+    asc.PS = asc.ObjectType == AAC_AOT_PS;
+    _,asc.ExtensionSamplingFrequency = readSamplingFrequency(br);
+    asc.ObjectType = readAudioObjectType(br);
+    if (asc.ObjectType == 22) {
+      br.Skip(4);
+    }
+  }
+
+  switch (asc.ObjectType) {
+  case 1, 2, 3, 4, 6, 7, 17, 19, 20, 21, 22, 23:
+    // the frame length flag selects 960 over 1024 samples, or 480 over
+    // 512 for the low delay profile
+    short := br.ReadFlag();
+    if (asc.ObjectType == AAC_AOT_ER_AAC_LD) {
+      asc.FrameLength = 512;
+      if (short) {
+        asc.FrameLength = 480;
+      }
+    } else {
+      asc.FrameLength = 1024;
+      if (short) {
+        asc.FrameLength = 960;
+      }
+    }
+    asc.DependsOnCoreCoder = br.ReadFlag();
+    if (asc.DependsOnCoreCoder) {
+      asc.CoreCoderDelay = uint16(br.ReadBits(14));
+    }
+    extensionFlag := br.ReadFlag();
+    if (asc.ChannelConfiguration == 0) {
+      // a program_config_element follows, which we do not decode, so
+      // nothing past this point can be located
+      if (br.Err() != nil) {
+        return nil, br.Err();
+      }
+      return &asc, nil;
+    }
+    if (asc.ObjectType == 6 || asc.ObjectType == 20) {
+      br.Skip(3);
+    }
+    if (extensionFlag) {
+      if (asc.ObjectType == 22) {
+        br.Skip(5 + 11);
+      }
+      if (asc.ObjectType == 17 || asc.ObjectType == 19 || asc.ObjectType == 20 || asc.ObjectType == 23) {
+        br.Skip(3);
+      }
+      br.Skip(1);
+    }
+  default:
+    if (br.Err() != nil) {
+      return nil, br.Err();
+    }
+    return &asc, nil;
+  }
+
+  switch (asc.ObjectType) {
+  case 17, 19, 20, 21, 22, 23, 24, 25, 26, 27, 39:
+    epConfig := br.ReadBits(2);
+    if (epConfig == 2 || epConfig == 3) {
+      return &asc, br.Err();
+    }
+  }
+
+  if (br.Err() != nil) {
+    return nil, br.Err();
+  }
+
+  if (asc.ExtensionObjectType != AAC_AOT_SBR && br.BitsLeft() >= 16) {
+    if (br.ReadBits(11) == 0x2b7) {
+      ext := readAudioObjectType(br);
+      if (ext == AAC_AOT_SBR) {
+        asc.SBR = br.ReadFlag();
+        if (asc.SBR) {
+          asc.ExtensionObjectType = ext;
+          asc.BackwardCompatible = true;
+          _,asc.ExtensionSamplingFrequency = readSamplingFrequency(br);
+          if (br.BitsLeft() >= 12 && br.ReadBits(11) == 0x548) {
+            asc.PS = br.ReadFlag();
+          }
+        }
+      }
+    }
+  }
+
+  // a truncated sync extension still leaves the core config usable
+  return &asc, nil;
+}
+
+// OutputSampleRate returns the rate the decoder outputs, which is the SBR
+// rate for HE-AAC streams.
+func (asc AudioSpecificConfig) OutputSampleRate() uint32 {
+  if (asc.SBR && asc.ExtensionSamplingFrequency != 0) {
+    return asc.ExtensionSamplingFrequency;
+  }
+  return asc.SamplingFrequency;
+}
+
+// ChannelCount returns the output channel count, PS streams decode to
+// stereo from a mono core.
+func (asc AudioSpecificConfig) ChannelCount() int {
+  if (asc.PS && asc.ChannelConfiguration == 1) {
+    return 2;
+  }
+  if (int(asc.ChannelConfiguration) < len(aacChannels)) {
+    return int(aacChannels[asc.ChannelConfiguration]);
+  }
+  return 0;
+}
+
+func (asc AudioSpecificConfig) Profile() string {
+  switch {
+  case asc.PS:
+    return "HE-AACv2";
+  case asc.SBR:
+    return "HE-AAC";
+  }
+
+  switch (asc.ObjectType) {
+  case AAC_AOT_MAIN:
+    return "AAC Main";
+  case AAC_AOT_LC:
+    return "AAC LC";
+  case AAC_AOT_SSR:
+    return "AAC SSR";
+  case AAC_AOT_LTP:
+    return "AAC LTP";
+  case 23:
+    return "AAC LD";
+  case 39:
+    return "AAC ELD";
+  case 42:
+    return "xHE-AAC";
+  }
+
+  return "";
+}
@@ -0,0 +1,269 @@
+package mp4
+
+import (
+  "errors"
+)
+
+const AV1_OBU_SEQUENCE_HEADER = 1;
+
+type AV1SequenceHeader struct {
+  Profile uint8 `json:"profile"`
+  StillPicture bool `json:"stillPicture"`
+  ReducedStillPictureHeader bool `json:"reducedStillPictureHeader"`
+  Level uint8 `json:"level"`
+  Tier uint8 `json:"tier"`
+  TimingInfoPresent bool `json:"timingInfoPresent"`
+  NumUnitsInDisplayTick uint32 `json:"numUnitsInDisplayTick"`
+  TimeScale uint32 `json:"timeScale"`
+  Width uint32 `json:"width"`
+  Height uint32 `json:"height"`
+  BitDepth uint8 `json:"bitDepth"`
+  MonoChrome bool `json:"monoChrome"`
+  ColorDescriptionPresent bool `json:"colorDescriptionPresent"`
+  ColorPrimaries uint8 `json:"colorPrimaries"`
+  TransferCharacteristics uint8 `json:"transferCharacteristics"`
+  MatrixCoefficients uint8 `json:"matrixCoefficients"`
+  FullRange bool `json:"fullRange"`
+  SubsamplingX uint8 `json:"subsamplingX"`
+  SubsamplingY uint8 `json:"subsamplingY"`
+  ChromaSamplePosition uint8 `json:"chromaSamplePosition"`
+  FilmGrainParamsPresent bool `json:"filmGrainParamsPresent"`
+}
+
+func readLeb128(data []byte) (uint64, int, error) {
+  var v uint64;
+
+  for i := 0; i < 8; i++ {
+    if (i >= len(data)) {
+      return 0, 0, errors.New("not enough data");
+    }
+    v |= uint64(data[i] & 0x7f) << uint(i * 7);
+    if ((data[i] & 0x80) == 0) {
+      return v, i + 1, nil;
+    }
+  }
+
+  return 0, 0, errors.New("invalid leb128 value");
+}
+
+func readUvlc(br *BitReader) uint32 {
+  lz := 0;
+
+  for !br.ReadFlag() {
+    if (br.Err() != nil) {
+      return 0;
+    }
+    lz++;
+  }
+
+  if (lz >= 32) {
+    return 0xffffffff;
+  }
+
+  return uint32(br.ReadBits(lz) + (1 << uint(lz)) - 1);
+}
+
+// FindAV1SequenceHeader walks a sequence of low overhead OBUs (such as
+// av1C configOBUs) and parses the first sequence header found.
+func FindAV1SequenceHeader(data []byte) (*AV1SequenceHeader, error) {
+  for len(data) > 0 {
+    obuType := (data[0] >> 3) & 0x0f;
+    hasExt := (data[0] & 0x04) != 0;
+    hasSize := (data[0] & 0x02) != 0;
+
+    hlen := 1;
+
+    if (hasExt) {
+      hlen++;
+    }
+
+    if (len(data) < hlen) {
+      return nil, errors.New("not enough data");
+    }
+
+    size := uint64(len(data) - hlen);
+
+    if (hasSize) {
+      v,n,err := readLeb128(data[hlen:]);
+      if (err != nil) {
+        return nil, err;
+      }
+      size = v;
+      hlen += n;
+    }
+
+    if (uint64(len(data) - hlen) < size) {
+      return nil, errors.New("not enough data");
+    }
+
+    payload := data[hlen:hlen + int(size)];
+
+    if (obuType == AV1_OBU_SEQUENCE_HEADER) {
+      return ParseAV1SequenceHeader(payload);
+    }
+
+    data = data[hlen + int(size):];
+  }
+
+  return nil, errors.New("sequence header not found");
+}
+
+// ParseAV1SequenceHeader parses a sequence_header_obu payload as defined in
+// section 5.5 of the AV1 specification.
+func ParseAV1SequenceHeader(data []byte) (*AV1SequenceHeader, error) {
+  sh := AV1SequenceHeader{};
+  br := NewBitReader(data);
+
+  sh.Profile = uint8(br.ReadBits(3));
+  sh.StillPicture = br.ReadFlag();
+  sh.ReducedStillPictureHeader = br.ReadFlag();
+
+  if (sh.ReducedStillPictureHeader) {
+    sh.Level = uint8(br.ReadBits(5));
+  } else {
+    decoderModelInfoPresent := false;
+    bufferDelayLen := 0;
+
+    sh.TimingInfoPresent = br.ReadFlag();
+
+    if (sh.TimingInfoPresent) {
+      sh.NumUnitsInDisplayTick = uint32(br.ReadBits(32));
+      sh.TimeScale = uint32(br.ReadBits(32));
+      if (br.ReadFlag()) {
+        readUvlc(br);
+      }
+      decoderModelInfoPresent = br.ReadFlag();
+      if (decoderModelInfoPresent) {
+        bufferDelayLen = int(br.ReadBits(5)) + 1;
+        br.Skip(32 + 5 + 5);
+      }
+    }
+
+    initialDisplayDelayPresent := br.ReadFlag();
+    ops := int(br.ReadBits(5)) + 1;
+
+    for i := 0; i < ops; i++ {
+      br.Skip(12);
+      level := uint8(br.ReadBits(5));
+      var tier uint8;
+      if (level > 7) {
+        tier = uint8(br.ReadBits(1));
+      }
+      if (i == 0) {
+        sh.Level = level;
+        sh.Tier = tier;
+      }
+      if (decoderModelInfoPresent && br.ReadFlag()) {
+        br.Skip(bufferDelayLen * 2 + 1);
+      }
+      if (initialDisplayDelayPresent && br.ReadFlag()) {
+        br.Skip(4);
+      }
+    }
+  }
+
+  wbits := int(br.ReadBits(4)) + 1;
+  hbits := int(br.ReadBits(4)) + 1;
+  sh.Width = uint32(br.ReadBits(wbits)) + 1;
+  sh.Height = uint32(br.ReadBits(hbits)) + 1;
+
+  if (!sh.ReducedStillPictureHeader && br.ReadFlag()) {
+    br.Skip(4 + 3);
+  }
+
+  // use_128x128_superblock, enable_filter_intra, enable_intra_edge_filter
+  br.Skip(3);
+
+  if (!sh.ReducedStillPictureHeader) {
+    // enable_interintra_compound, enable_masked_compound,
+    // enable_warped_motion, enable_dual_filter
+    br.Skip(4);
+    orderHint := br.ReadFlag();
+    if (orderHint) {
+      br.Skip(2);
+    }
+    forceScreenContentTools := uint64(2);
+    if (!br.ReadFlag()) {
+      forceScreenContentTools = br.ReadBits(1);
+    }
+    if (forceScreenContentTools > 0 && !br.ReadFlag()) {
+      br.Skip(1);
+    }
+    if (orderHint) {
+      br.Skip(3);
+    }
+  }
+
+  // enable_superres, enable_cdef, enable_restoration
+  br.Skip(3);
+
+  highBitdepth := br.ReadFlag();
+  sh.BitDepth = 8;
+
+  if (sh.Profile == 2 && highBitdepth) {
+    sh.BitDepth = 10;
+    if (br.ReadFlag()) {
+      sh.BitDepth = 12;
+    }
+  } else if (highBitdepth) {
+    sh.BitDepth = 10;
+  }
+
+  if (sh.Profile != 1) {
+    sh.MonoChrome = br.ReadFlag();
+  }
+
+  sh.ColorPrimaries = 2;
+  sh.TransferCharacteristics = 2;
+  sh.MatrixCoefficients = 2;
+
+  sh.ColorDescriptionPresent = br.ReadFlag();
+
+  if (sh.ColorDescriptionPresent) {
+    sh.ColorPrimaries = uint8(br.ReadBits(8));
+    sh.TransferCharacteristics = uint8(br.ReadBits(8));
+    sh.MatrixCoefficients = uint8(br.ReadBits(8));
+  }
+
+  if (sh.MonoChrome) {
+    sh.FullRange = br.ReadFlag();
+    sh.SubsamplingX = 1;
+    sh.SubsamplingY = 1;
+  } else if (sh.ColorPrimaries == 1 && sh.TransferCharacteristics == 13 && sh.MatrixCoefficients == 0) {
+    // sRGB
+    sh.FullRange = true;
+  } else {
+    sh.FullRange = br.ReadFlag();
+    switch (sh.Profile) {
+    case 0:
+      sh.SubsamplingX = 1;
+      sh.SubsamplingY = 1;
+    case 1:
+    default:
+      if (sh.BitDepth == 12) {
+        sh.SubsamplingX = uint8(br.ReadBits(1));
+        if (sh.SubsamplingX == 1) {
+          sh.SubsamplingY = uint8(br.ReadBits(1));
+        }
+      } else {
+        sh.SubsamplingX = 1;
+      }
+    }
+    if (sh.SubsamplingX == 1 && sh.SubsamplingY == 1) {
+      sh.ChromaSamplePosition = uint8(br.ReadBits(2));
+    }
+  }
+
+  if (!sh.MonoChrome) {
+    // separate_uv_delta_q
+    br.Skip(1);
+  }
+
+  sh.FilmGrainParamsPresent = br.ReadFlag();
+
+  if (br.Err() != nil) {
+    return nil, br.Err();
+  }
+
+  return &sh, nil;
+}
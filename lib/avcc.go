@@ -0,0 +1,86 @@
+package mp4
+
+import (
+  "errors"
+  "encoding/binary"
+)
+
+func avccHasExtension(profile uint8) bool {
+  switch (profile) {
+  case 100, 110, 122, 144, 244:
+    return true;
+  }
+  return false;
+}
+
+func appendNALUs(buf []byte, nalus [][]byte) ([]byte, error) {
+  for _,nalu := range nalus {
+    if (len(nalu) > 0xffff) {
+      return nil, errors.New("parameter set NAL unit too large");
+    }
+    size := make([]byte, 2);
+    binary.BigEndian.PutUint16(size, uint16(len(nalu)));
+    buf = append(buf, size...);
+    buf = append(buf, nalu...);
+  }
+  return buf, nil;
+}
+
+// Serialize writes the AVCDecoderConfigurationRecord back out, that is the
+// avcC box payload without the box header. It fails when the parameter
+// sets do not fit the record's count and length fields.
+func (avcc AVCcBox) Serialize() ([]byte, error) {
+  sizeLen := avcc.SizeLen;
+
+  if (sizeLen == 0) {
+    sizeLen = 4;
+  }
+
+  if (len(avcc.SPS) > 0x1f) {
+    return nil, errors.New("too many SPS for avcC");
+  }
+
+  if (len(avcc.PPS) > 0xff) {
+    return nil, errors.New("too many PPS for avcC");
+  }
+
+  if (len(avcc.SPSExt) > 0xff) {
+    return nil, errors.New("too many SPS extensions for avcC");
+  }
+
+  buf := []byte{
+    avcc.Version,
+    avcc.Profile,
+    avcc.Compatibility,
+    avcc.Level,
+    0xfc | ((sizeLen - 1) & 0x03),
+    0xe0 | uint8(len(avcc.SPS)),
+  };
+
+  buf,err := appendNALUs(buf, avcc.SPS);
+
+  if (err != nil) {
+    return nil, err;
+  }
+
+  buf = append(buf, uint8(len(avcc.PPS)));
+  buf,err = appendNALUs(buf, avcc.PPS);
+
+  if (err != nil) {
+    return nil, err;
+  }
+
+  if (avcc.HasExtension) {
+    buf = append(buf,
+      0xfc | (avcc.ChromaFormat & 0x03),
+      0xf8 | ((avcc.BitDepthLuma - 8) & 0x07),
+      0xf8 | ((avcc.BitDepthChroma - 8) & 0x07),
+      uint8(len(avcc.SPSExt)));
+    buf,err = appendNALUs(buf, avcc.SPSExt);
+    if (err != nil) {
+      return nil, err;
+    }
+  }
+
+  return buf, nil;
+}
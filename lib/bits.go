@@ -0,0 +1,157 @@
+package mp4
+
+import (
+  "errors"
+)
+
+// BitReader reads MSB first bit fields out of a byte slice. Reading past the
+// end of the data sets a sticky error, returned by Err, and yields zeros so
+// that bitstream parsers only need to check for failure once.
+type BitReader struct {
+  data []byte
+  pos int
+  err error
+}
+
+func NewBitReader(data []byte) *BitReader {
+  return &BitReader{data: data};
+}
+
+func (br *BitReader) ReadBits(n int) uint64 {
+  var v uint64;
+
+  if (br.err != nil) {
+    return 0;
+  }
+
+  if (br.pos + n > len(br.data) * 8) {
+    br.err = errors.New("not enough data");
+    return 0;
+  }
+
+  for i := 0; i < n; i++ {
+    bit := (br.data[br.pos >> 3] >> uint(7 - (br.pos & 0x07))) & 0x01;
+    v = (v << 1) | uint64(bit);
+    br.pos++;
+  }
+
+  return v;
+}
+
+func (br *BitReader) ReadFlag() bool {
+  return br.ReadBits(1) == 1;
+}
+
+func (br *BitReader) Skip(n int) {
+  br.ReadBits(n);
+}
+
+// BitsLeft returns the number of unread bits.
+func (br *BitReader) BitsLeft() int {
+  return len(br.data) * 8 - br.pos;
+}
+
+// MoreRBSPData reports whether there is payload left before the
+// rbsp_trailing_bits, more_rbsp_data() in the H.264 specification.
+func (br *BitReader) MoreRBSPData() bool {
+  last := len(br.data) * 8 - 1;
+
+  for last >= 0 && ((br.data[last >> 3] >> uint(7 - (last & 0x07))) & 0x01) == 0 {
+    last--;
+  }
+
+  return br.pos < last;
+}
+
+func (br *BitReader) Err() error {
+  return br.err;
+}
+
+// ReadUE reads an unsigned Exp-Golomb code, ue(v) in the H.264/H.265
+// specifications.
+func (br *BitReader) ReadUE() uint32 {
+  lz := 0;
+
+  for !br.ReadFlag() {
+    if (br.err != nil) {
+      return 0;
+    }
+    if (lz > 31) {
+      br.err = errors.New("invalid exp-golomb code");
+      return 0;
+    }
+    lz++;
+  }
+
+  return uint32((uint64(1) << uint(lz)) - 1 + br.ReadBits(lz));
+}
+
+// ReadSE reads a signed Exp-Golomb code, se(v).
+func (br *BitReader) ReadSE() int32 {
+  v := br.ReadUE();
+
+  if ((v & 0x01) == 1) {
+    return int32((v + 1) / 2);
+  }
+
+  return -int32(v / 2);
+}
+
+// UnescapeRBSP strips the emulation prevention bytes (the 0x03 in
+// 0x000003) from a NAL unit payload.
+func UnescapeRBSP(data []byte) []byte {
+  res := make([]byte, 0, len(data));
+  zeros := 0;
+
+  for _,b := range data {
+    if (zeros >= 2 && b == 0x03) {
+      zeros = 0;
+      continue;
+    }
+    if (b == 0x00) {
+      zeros++;
+    } else {
+      zeros = 0;
+    }
+    res = append(res, b);
+  }
+
+  return res;
+}
+
+// escapedSize returns the number of bytes of data, an escaped NAL unit
+// payload, holding its first n RBSP bytes.
+func escapedSize(data []byte, n int) int {
+  kept := 0;
+  zeros := 0;
+
+  for i,b := range data {
+    if (kept == n) {
+      return i;
+    }
+    if (zeros >= 2 && b == 0x03) {
+      zeros = 0;
+      continue;
+    }
+    if (b == 0x00) {
+      zeros++;
+    } else {
+      zeros = 0;
+    }
+    kept++;
+  }
+
+  return len(data);
+}
+
+// ceilLog2 returns Ceil(Log2(n)), the width of the u(v) fields indexing n
+// entries.
+func ceilLog2(n uint32) int {
+  bits := 0;
+
+  for (uint64(1) << uint(bits)) < uint64(n) {
+    bits++;
+  }
+
+  return bits;
+}
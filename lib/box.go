@@ -184,11 +184,318 @@ func parsePixelAspectRatioBox(data []byte, b *Box) (*PixelAspectRatioBox, error)
   return &pasp, nil;
 }
 
+func parseColourInformationBox(data []byte, b *Box) (*ColourInformationBox, error) {
+  colr := ColourInformationBox{Box: *b};
+
+  colr.ColourType = string(data[0:4]);
+  data = data[4:];
+
+  switch (colr.ColourType) {
+  case "nclx", "nclc":
+    colr.ColourPrimaries = binary.BigEndian.Uint16(data[0:2]);
+    colr.TransferCharacteristics = binary.BigEndian.Uint16(data[2:4]);
+    colr.MatrixCoefficients = binary.BigEndian.Uint16(data[4:6]);
+    // the QuickTime nclc variant has no range flag
+    if (colr.ColourType == "nclx" && len(data) > 6) {
+      colr.FullRange = (data[6] & 0x80) != 0;
+    }
+  case "rICC", "prof":
+    colr.ICCProfile = make([]byte, len(data));
+    copy(colr.ICCProfile, data);
+  }
+
+  return &colr, nil;
+}
+
+func parseCleanApertureBox(data []byte, b *Box) (*CleanApertureBox, error) {
+  clap := CleanApertureBox{Box: *b};
+  clap.WidthN = binary.BigEndian.Uint32(data[0:4]);
+  clap.WidthD = binary.BigEndian.Uint32(data[4:8]);
+  clap.HeightN = binary.BigEndian.Uint32(data[8:12]);
+  clap.HeightD = binary.BigEndian.Uint32(data[12:16]);
+  clap.HorizOffN = int32(binary.BigEndian.Uint32(data[16:20]));
+  clap.HorizOffD = binary.BigEndian.Uint32(data[20:24]);
+  clap.VertOffN = int32(binary.BigEndian.Uint32(data[24:28]));
+  clap.VertOffD = binary.BigEndian.Uint32(data[28:32]);
+  return &clap, nil;
+}
+
+func parseBitRateBox(data []byte, b *Box) (*BitRateBox, error) {
+  btrt := BitRateBox{Box: *b};
+  btrt.BufferSizeDB = binary.BigEndian.Uint32(data[0:4]);
+  btrt.MaxBitrate = binary.BigEndian.Uint32(data[4:8]);
+  btrt.AvgBitrate = binary.BigEndian.Uint32(data[8:12]);
+  return &btrt, nil;
+}
+
+func parseFieldHandlingBox(data []byte, b *Box) (*FieldHandlingBox, error) {
+  fiel := FieldHandlingBox{Box: *b};
+  fiel.FieldCount = data[0];
+  fiel.FieldOrdering = data[1];
+  return &fiel, nil;
+}
+
+func parseGammaBox(data []byte, b *Box) (*GammaBox, error) {
+  gama := GammaBox{Box: *b};
+  gama.Gamma = float32(binary.BigEndian.Uint32(data[0:4])) / float32(math.Pow(2, 16));
+  return &gama, nil;
+}
+
+func parseMasteringDisplayColourVolumeBox(data []byte, b *Box) (*MasteringDisplayColourVolumeBox, error) {
+  mdcv := MasteringDisplayColourVolumeBox{Box: *b};
+
+  for i := 0; i < 3; i++ {
+    mdcv.DisplayPrimariesX[i] = binary.BigEndian.Uint16(data[0:2]);
+    mdcv.DisplayPrimariesY[i] = binary.BigEndian.Uint16(data[2:4]);
+    data = data[4:];
+  }
+
+  mdcv.WhitePointX = binary.BigEndian.Uint16(data[0:2]);
+  mdcv.WhitePointY = binary.BigEndian.Uint16(data[2:4]);
+  mdcv.MaxLuminance = binary.BigEndian.Uint32(data[4:8]);
+  mdcv.MinLuminance = binary.BigEndian.Uint32(data[8:12]);
+
+  return &mdcv, nil;
+}
+
+func parseContentLightLevelBox(data []byte, b *Box) (*ContentLightLevelBox, error) {
+  clli := ContentLightLevelBox{Box: *b};
+  clli.MaxContentLightLevel = binary.BigEndian.Uint16(data[0:2]);
+  clli.MaxPicAverageLightLevel = binary.BigEndian.Uint16(data[2:4]);
+  return &clli, nil;
+}
+
+func parseContentColourVolumeBox(data []byte, b *Box) (*ContentColourVolumeBox, error) {
+  if (len(data) < 1) {
+    return nil, errors.New("invalid cclv box");
+  }
+
+  cclv := ContentColourVolumeBox{Box: *b};
+
+  // the two low bits are reserved
+  cclv.Cancel = (data[0] & 0x80) != 0;
+  cclv.Persistence = (data[0] & 0x40) != 0;
+  cclv.PrimariesPresent = (data[0] & 0x20) != 0;
+  cclv.MinLuminancePresent = (data[0] & 0x10) != 0;
+  cclv.MaxLuminancePresent = (data[0] & 0x08) != 0;
+  cclv.AvgLuminancePresent = (data[0] & 0x04) != 0;
+  data = data[1:];
+
+  if (cclv.PrimariesPresent) {
+    if (len(data) < 24) {
+      return nil, errors.New("invalid cclv box");
+    }
+    for i := 0; i < 3; i++ {
+      cclv.PrimariesX[i] = int32(binary.BigEndian.Uint32(data[0:4]));
+      cclv.PrimariesY[i] = int32(binary.BigEndian.Uint32(data[4:8]));
+      data = data[8:];
+    }
+  }
+
+  if (cclv.MinLuminancePresent) {
+    if (len(data) < 4) {
+      return nil, errors.New("invalid cclv box");
+    }
+    cclv.MinLuminance = binary.BigEndian.Uint32(data[0:4]);
+    data = data[4:];
+  }
+
+  if (cclv.MaxLuminancePresent) {
+    if (len(data) < 4) {
+      return nil, errors.New("invalid cclv box");
+    }
+    cclv.MaxLuminance = binary.BigEndian.Uint32(data[0:4]);
+    data = data[4:];
+  }
+
+  if (cclv.AvgLuminancePresent) {
+    if (len(data) < 4) {
+      return nil, errors.New("invalid cclv box");
+    }
+    cclv.AvgLuminance = binary.BigEndian.Uint32(data[0:4]);
+  }
+
+  return &cclv, nil;
+}
+
+func parseAmbientViewingEnvironmentBox(data []byte, b *Box) (*AmbientViewingEnvironmentBox, error) {
+  amve := AmbientViewingEnvironmentBox{Box: *b};
+  amve.AmbientIlluminance = binary.BigEndian.Uint32(data[0:4]);
+  amve.AmbientLightX = binary.BigEndian.Uint16(data[4:6]);
+  amve.AmbientLightY = binary.BigEndian.Uint16(data[6:8]);
+  return &amve, nil;
+}
+
+func parseDolbyVisionConfigBox(data []byte, b *Box) (*DolbyVisionConfigBox, error) {
+  dv := DolbyVisionConfigBox{Box: *b};
+
+  dv.VersionMajor = data[0];
+  dv.VersionMinor = data[1];
+
+  flags := binary.BigEndian.Uint16(data[2:4]);
+
+  dv.Profile = uint8(flags >> 9);
+  dv.Level = uint8((flags >> 3) & 0x3f);
+  dv.RPUPresent = (flags & 0x04) != 0;
+  dv.ELPresent = (flags & 0x02) != 0;
+  dv.BLPresent = (flags & 0x01) != 0;
+  dv.BLSignalCompatibilityID = data[4] >> 4;
+
+  return &dv, nil;
+}
+
+// readCString returns the null terminated string at the start of data and
+// what follows it.
+func readCString(data []byte) (string, []byte) {
+  for i := range data {
+    if (data[i] == 0) {
+      return string(data[:i]), data[i + 1:];
+    }
+  }
+  return string(data), nil;
+}
+
+// iterBoxes calls fn for each child box found in data.
+func iterBoxes(data []byte, fn func(b *Box, payload []byte)) error {
+  for len(data) >= BOX_HDR_SZ {
+    b,err := parseBox(data);
+
+    if (err != nil) {
+      return err;
+    }
+
+    if (b.Size < b.headerSize || b.Size > uint64(len(data))) {
+      return errors.New("invalid box size");
+    }
+
+    fn(b, data[b.headerSize:b.Size]);
+
+    data = data[b.Size:];
+  }
+
+  return nil;
+}
+
+// walkBoxes is iterBoxes printing the box tree as it goes.
+func walkBoxes(data []byte, indent string, fn func(b *Box, payload []byte)) error {
+  return iterBoxes(data, func(b *Box, payload []byte) {
+    fmt.Println(indent + "-", b.Type);
+    fn(b, payload);
+  });
+}
+
+func parseStereoscopic3DBox(data []byte, b *Box) (*Stereoscopic3DBox, error) {
+  fb,_ := parseFullBox(data, b);
+  st3d := Stereoscopic3DBox{Box: *fb};
+  st3d.StereoMode = data[4];
+  return &st3d, nil;
+}
+
+func parseProjectionBox(data []byte, b *Box) (*ProjectionBox, error) {
+  proj := ProjectionBox{Box: *b};
+
+  err := walkBoxes(data, "                  ", func(b *Box, data []byte) {
+    switch (b.Type) {
+    case "prhd":
+      data = data[4:];
+      proj.Yaw = float32(int32(binary.BigEndian.Uint32(data[0:4]))) / float32(math.Pow(2, 16));
+      proj.Pitch = float32(int32(binary.BigEndian.Uint32(data[4:8]))) / float32(math.Pow(2, 16));
+      proj.Roll = float32(int32(binary.BigEndian.Uint32(data[8:12]))) / float32(math.Pow(2, 16));
+    case "equi":
+      data = data[4:];
+      proj.ProjectionType = b.Type;
+      proj.Equirectangular = &EquirectangularProjection{
+        BoundsTop: binary.BigEndian.Uint32(data[0:4]),
+        BoundsBottom: binary.BigEndian.Uint32(data[4:8]),
+        BoundsLeft: binary.BigEndian.Uint32(data[8:12]),
+        BoundsRight: binary.BigEndian.Uint32(data[12:16]),
+      };
+    case "cbmp":
+      data = data[4:];
+      proj.ProjectionType = b.Type;
+      proj.Cubemap = &CubemapProjection{
+        Layout: binary.BigEndian.Uint32(data[0:4]),
+        Padding: binary.BigEndian.Uint32(data[4:8]),
+      };
+    case "mshp":
+      proj.ProjectionType = b.Type;
+    }
+  });
+
+  if (err != nil) {
+    return nil, err;
+  }
+
+  return &proj, nil;
+}
+
+func parseSphericalVideoBox(data []byte, b *Box) (*SphericalVideoBox, error) {
+  sv3d := SphericalVideoBox{Box: *b};
+
+  err := walkBoxes(data, "                ", func(b *Box, data []byte) {
+    switch (b.Type) {
+    case "svhd":
+      sv3d.MetadataSource,_ = readCString(data[4:]);
+    case "proj":
+      proj,err := parseProjectionBox(data, b);
+      if (err != nil) {
+        fmt.Println(err);
+        return;
+      }
+      sv3d.Projection = *proj;
+    }
+  });
+
+  if (err != nil) {
+    return nil, err;
+  }
+
+  return &sv3d, nil;
+}
+
+func parseVideoExtendedUsageBox(data []byte, b *Box) (*VideoExtendedUsageBox, error) {
+  vexu := VideoExtendedUsageBox{Box: *b};
+
+  var walk func(b *Box, data []byte);
+
+  walk = func(b *Box, data []byte) {
+    switch (b.Type) {
+    case "eyes", "cams", "cmfy", "proj":
+      err := walkBoxes(data, "                  ", walk);
+      if (err != nil) {
+        fmt.Println(err);
+      }
+    case "stri":
+      vexu.EyeViewsReversed = (data[4] & 0x08) != 0;
+      vexu.HasAdditionalViews = (data[4] & 0x04) != 0;
+      vexu.HasRightEye = (data[4] & 0x02) != 0;
+      vexu.HasLeftEye = (data[4] & 0x01) != 0;
+    case "hero":
+      vexu.HeroEye = data[4];
+    case "blin":
+      vexu.Baseline = binary.BigEndian.Uint32(data[4:8]);
+    case "dadj":
+      vexu.DisparityAdjustment = int32(binary.BigEndian.Uint32(data[4:8]));
+    case "prji":
+      vexu.ProjectionKind = string(data[4:8]);
+    }
+  };
+
+  err := walkBoxes(data, "                ", walk);
+
+  if (err != nil) {
+    return nil, err;
+  }
+
+  return &vexu, nil;
+}
+
 func parseAVCcBox(data []byte, b *Box) (*AVCcBox, error) {
   avcc := AVCcBox{Box: *b};
 
   avcc.Version = data[0];
   avcc.Profile = data[1];
+  avcc.Compatibility = data[2];
   avcc.Level = data[3];
   avcc.SizeLen = (data[4] & 0x03) + 1;
 
@@ -214,36 +521,349 @@ func parseAVCcBox(data []byte, b *Box) (*AVCcBox, error) {
     data = data[len + 2:];
   }
 
+  // High profiles carry extra fields, though some muxers leave them out
+  if (avccHasExtension(avcc.Profile) && len(data) >= 4) {
+    avcc.HasExtension = true;
+    avcc.ChromaFormat = data[0] & 0x03;
+    avcc.BitDepthLuma = (data[1] & 0x07) + 8;
+    avcc.BitDepthChroma = (data[2] & 0x07) + 8;
+
+    next := int(data[3]);
+    data = data[4:];
+
+    for i := 0; i < next; i++ {
+      len := binary.BigEndian.Uint16(data[0:2]);
+      ext := make([]byte, len);
+      copy(ext, data[2: len + 2]);
+      avcc.SPSExt = append(avcc.SPSExt, ext);
+      data = data[len + 2:];
+    }
+  }
+
   return &avcc, nil;
 }
 
+func parseHVCcBox(data []byte, b *Box) (*HVCcBox, error) {
+  hvcc := HVCcBox{Box: *b};
+
+  hvcc.Version = data[0];
+  hvcc.ProfileSpace = data[1] >> 6;
+  hvcc.TierFlag = (data[1] >> 5) & 0x01;
+  hvcc.ProfileIdc = data[1] & 0x1f;
+  hvcc.ProfileCompatibility = binary.BigEndian.Uint32(data[2:6]);
+  copy(hvcc.ConstraintIndicator[:], data[6:12]);
+  hvcc.Level = data[12];
+  hvcc.MinSpatialSegmentation = binary.BigEndian.Uint16(data[13:15]) & 0x0fff;
+  hvcc.ParallelismType = data[15] & 0x03;
+  hvcc.ChromaFormat = data[16] & 0x03;
+  hvcc.BitDepthLuma = (data[17] & 0x07) + 8;
+  hvcc.BitDepthChroma = (data[18] & 0x07) + 8;
+  hvcc.AvgFrameRate = binary.BigEndian.Uint16(data[19:21]);
+  hvcc.ConstantFrameRate = data[21] >> 6;
+  hvcc.NumTemporalLayers = (data[21] >> 3) & 0x07;
+  hvcc.TemporalIdNested = (data[21] & 0x04) != 0;
+  hvcc.SizeLen = (data[21] & 0x03) + 1;
+
+  narrays := int(data[22]);
+  data = data[23:];
+
+  for i := 0; i < narrays; i++ {
+    naltype := data[0] & 0x3f;
+    nnalus := int(binary.BigEndian.Uint16(data[1:3]));
+    data = data[3:];
+
+    for j := 0; j < nnalus; j++ {
+      len := binary.BigEndian.Uint16(data[0:2]);
+      nalu := make([]byte, len);
+      copy(nalu, data[2: len + 2]);
+      data = data[len + 2:];
+
+      switch (naltype) {
+      case 32:
+        hvcc.VPS = append(hvcc.VPS, nalu);
+      case 33:
+        hvcc.SPS = append(hvcc.SPS, nalu);
+      case 34:
+        hvcc.PPS = append(hvcc.PPS, nalu);
+      case 39, 40:
+        hvcc.SEI = append(hvcc.SEI, nalu);
+      }
+    }
+  }
+
+  return &hvcc, nil;
+}
+
+func parseAV1cBox(data []byte, b *Box) (*AV1cBox, error) {
+  av1c := AV1cBox{Box: *b};
+
+  av1c.Version = data[0] & 0x7f;
+  av1c.SeqProfile = data[1] >> 5;
+  av1c.SeqLevelIdx = data[1] & 0x1f;
+  av1c.SeqTier = data[2] >> 7;
+
+  av1c.BitDepth = 8;
+
+  if ((data[2] & 0x40) != 0) {
+    av1c.BitDepth = 10;
+    if ((data[2] & 0x20) != 0) {
+      av1c.BitDepth = 12;
+    }
+  }
+
+  av1c.Monochrome = (data[2] & 0x10) != 0;
+  av1c.ChromaSubsamplingX = (data[2] >> 3) & 0x01;
+  av1c.ChromaSubsamplingY = (data[2] >> 2) & 0x01;
+  av1c.ChromaSamplePosition = data[2] & 0x03;
+  av1c.InitialPresentationDelayPresent = (data[3] & 0x10) != 0;
+
+  if (av1c.InitialPresentationDelayPresent) {
+    av1c.InitialPresentationDelay = (data[3] & 0x0f) + 1;
+  }
+
+  av1c.ConfigOBUs = make([]byte, len(data) - 4);
+  copy(av1c.ConfigOBUs, data[4:]);
+
+  if (len(av1c.ConfigOBUs) > 0) {
+    sh,err := FindAV1SequenceHeader(av1c.ConfigOBUs);
+    if (err != nil) {
+      fmt.Println(err);
+    }
+    av1c.SequenceHeader = sh;
+  }
+
+  return &av1c, nil;
+}
+
+func parseVPcCBox(data []byte, b *Box) (*VPcCBox, error) {
+  fb,_ := parseFullBox(data, b);
+  vpcc := VPcCBox{Box: *fb};
+  data = data[4:];
+
+  vpcc.Profile = data[0];
+  vpcc.Level = data[1];
+  vpcc.BitDepth = data[2] >> 4;
+
+  if (fb.Version == 0) {
+    // the draft layout only carries a colour space and a transfer function
+    vpcc.ChromaSubsampling = data[3] >> 4;
+    vpcc.TransferCharacteristics = (data[3] >> 1) & 0x07;
+    vpcc.FullRange = (data[3] & 0x01) != 0;
+    data = data[4:];
+  } else {
+    vpcc.ChromaSubsampling = (data[2] >> 1) & 0x07;
+    vpcc.FullRange = (data[2] & 0x01) != 0;
+    vpcc.ColourPrimaries = data[3];
+    vpcc.TransferCharacteristics = data[4];
+    vpcc.MatrixCoefficients = data[5];
+    data = data[6:];
+  }
+
+  len := binary.BigEndian.Uint16(data[0:2]);
+  vpcc.CodecInitData = make([]byte, len);
+  copy(vpcc.CodecInitData, data[2:len + 2]);
+
+  return &vpcc, nil;
+}
+
+func parseTrackEncryptionBox(data []byte, b *Box) (*TrackEncryptionBox, error) {
+  if (len(data) < 24) {
+    return nil, errors.New("invalid tenc box");
+  }
+
+  fb,_ := parseFullBox(data, b);
+  tenc := TrackEncryptionBox{Box: *fb};
+
+  data = data[4:];
+
+  if (fb.Version > 0) {
+    tenc.DefaultCryptByteBlock = data[1] >> 4;
+    tenc.DefaultSkipByteBlock = data[1] & 0x0f;
+  }
+
+  tenc.DefaultIsProtected = data[2];
+  tenc.DefaultPerSampleIVSize = data[3];
+  copy(tenc.DefaultKID[:], data[4:20]);
+
+  data = data[20:];
+
+  if (tenc.DefaultIsProtected == 1 && tenc.DefaultPerSampleIVSize == 0) {
+    if (len(data) < 1 || len(data) < 1 + int(data[0])) {
+      return nil, errors.New("invalid tenc box");
+    }
+    tenc.DefaultConstantIV = data[1:1 + int(data[0])];
+  }
+
+  return &tenc, nil;
+}
+
+func parseProtectionSchemeInfoBox(data []byte, b *Box) (*ProtectionSchemeInfoBox, error) {
+  sinf := ProtectionSchemeInfoBox{Box: *b};
+  var perr error;
+
+  err := walkBoxes(data, "                ", func(b *Box, data []byte) {
+    switch (b.Type) {
+    case "frma":
+      if (len(data) >= 4) {
+        sinf.Frma = OriginalFormatBox{Box: *b, DataFormat: string(data[0:4])};
+      }
+    case "schm":
+      if (len(data) < 12) {
+        perr = errors.New("invalid schm box");
+        return;
+      }
+      fb,_ := parseFullBox(data, b);
+      sinf.Schm = SchemeTypeBox{Box: *fb};
+      sinf.Schm.SchemeType = string(data[4:8]);
+      sinf.Schm.SchemeVersion = binary.BigEndian.Uint32(data[8:12]);
+      if ((fb.Flags[2] & 0x01) != 0) {
+        sinf.Schm.SchemeURI,_ = readCString(data[12:]);
+      }
+    case "schi":
+      perr = walkBoxes(data, "                  ", func(b *Box, data []byte) {
+        if (b.Type == "tenc") {
+          tenc,err := parseTrackEncryptionBox(data, b);
+          if (err != nil) {
+            perr = err;
+            return;
+          }
+          sinf.Tenc = *tenc;
+        }
+      });
+    }
+  });
+
+  if (err == nil) {
+    err = perr;
+  }
+
+  if (err != nil) {
+    return nil, err;
+  }
+
+  return &sinf, nil;
+}
+
 func parseVideoSampleDesc(data []byte, entry *SampleEntry) (*VideoSampleDescription, error) {
   vsd := VideoSampleDescription{};
   vsd.Width = binary.BigEndian.Uint16(data[16:18]);
   vsd.Height = binary.BigEndian.Uint16(data[18:20]);
+  vsd.HorizResolution = float32(binary.BigEndian.Uint32(data[20:24])) / float32(math.Pow(2, 16));
+  vsd.VertResolution = float32(binary.BigEndian.Uint32(data[24:28])) / float32(math.Pow(2, 16));
+  vsd.FrameCount = binary.BigEndian.Uint16(data[32:34]);
+
+  // pascal string in a fixed 32 bytes field
+  nlen := int(data[34]);
+
+  if (nlen > 31) {
+    nlen = 31;
+  }
+
+  vsd.CompressorName = string(data[35:35 + nlen]);
   vsd.Depth = binary.BigEndian.Uint16(data[66:68]);
-  // TODO add missing info
 
   data = data[70:];
 
   tsize := entry.Box.headerSize + 8 + 70;
 
-  for {
+  // a 4 byte terminator may follow the last extension
+  for tsize + BOX_HDR_SZ <= entry.Box.Size {
     b,err := parseBox(data);
 
     if (err != nil) {
       return nil, err;
     }
 
+    if (b.Size < b.headerSize || tsize + b.Size > entry.Box.Size) {
+      return nil, errors.New("invalid box size");
+    }
+
     fmt.Println("              -", b.Type);
 
     switch (b.Type) {
     case "avcC":
       avcc,_ := parseAVCcBox(data[b.headerSize:b.Size], b);
       entry.Extensions = append(entry.Extensions, *avcc);
+    case "esds":
+      esds,_ := parseElementaryStreamDescBox(data[b.headerSize:b.Size], b);
+      entry.Extensions = append(entry.Extensions, *esds);
+    case "d263":
+      d263,err := parseH263SpecificBox(data[b.headerSize:b.Size], b);
+      if (err != nil) {
+        fmt.Println(err);
+        break;
+      }
+      entry.Extensions = append(entry.Extensions, *d263);
+    case "hvcC":
+      hvcc,_ := parseHVCcBox(data[b.headerSize:b.Size], b);
+      entry.Extensions = append(entry.Extensions, *hvcc);
+    case "av1C":
+      av1c,_ := parseAV1cBox(data[b.headerSize:b.Size], b);
+      entry.Extensions = append(entry.Extensions, *av1c);
+    case "vpcC":
+      vpcc,_ := parseVPcCBox(data[b.headerSize:b.Size], b);
+      entry.Extensions = append(entry.Extensions, *vpcc);
     case "pasp":
       pasp,_ := parsePixelAspectRatioBox(data[b.headerSize:b.Size], b);
       entry.Extensions = append(entry.Extensions, *pasp);
+    case "colr":
+      colr,_ := parseColourInformationBox(data[b.headerSize:b.Size], b);
+      entry.Extensions = append(entry.Extensions, *colr);
+    case "clap":
+      clap,_ := parseCleanApertureBox(data[b.headerSize:b.Size], b);
+      entry.Extensions = append(entry.Extensions, *clap);
+    case "btrt":
+      btrt,_ := parseBitRateBox(data[b.headerSize:b.Size], b);
+      entry.Extensions = append(entry.Extensions, *btrt);
+    case "fiel":
+      fiel,_ := parseFieldHandlingBox(data[b.headerSize:b.Size], b);
+      entry.Extensions = append(entry.Extensions, *fiel);
+    case "gama":
+      gama,_ := parseGammaBox(data[b.headerSize:b.Size], b);
+      entry.Extensions = append(entry.Extensions, *gama);
+    case "mdcv":
+      mdcv,_ := parseMasteringDisplayColourVolumeBox(data[b.headerSize:b.Size], b);
+      entry.Extensions = append(entry.Extensions, *mdcv);
+    case "clli":
+      clli,_ := parseContentLightLevelBox(data[b.headerSize:b.Size], b);
+      entry.Extensions = append(entry.Extensions, *clli);
+    case "cclv":
+      cclv,err := parseContentColourVolumeBox(data[b.headerSize:b.Size], b);
+      if (err != nil) {
+        fmt.Println(err);
+        break;
+      }
+      entry.Extensions = append(entry.Extensions, *cclv);
+    case "amve":
+      amve,_ := parseAmbientViewingEnvironmentBox(data[b.headerSize:b.Size], b);
+      entry.Extensions = append(entry.Extensions, *amve);
+    case "dvcC", "dvvC", "dvwC":
+      dv,_ := parseDolbyVisionConfigBox(data[b.headerSize:b.Size], b);
+      entry.Extensions = append(entry.Extensions, *dv);
+    case "st3d":
+      st3d,_ := parseStereoscopic3DBox(data[b.headerSize:b.Size], b);
+      entry.Extensions = append(entry.Extensions, *st3d);
+    case "sv3d":
+      sv3d,err := parseSphericalVideoBox(data[b.headerSize:b.Size], b);
+      if (err != nil) {
+        fmt.Println(err);
+        break;
+      }
+      entry.Extensions = append(entry.Extensions, *sv3d);
+    case "vexu":
+      vexu,err := parseVideoExtendedUsageBox(data[b.headerSize:b.Size], b);
+      if (err != nil) {
+        fmt.Println(err);
+        break;
+      }
+      entry.Extensions = append(entry.Extensions, *vexu);
+    case "sinf":
+      sinf,err := parseProtectionSchemeInfoBox(data[b.headerSize:b.Size], b);
+      if (err != nil) {
+        fmt.Println(err);
+        break;
+      }
+      entry.Extensions = append(entry.Extensions, *sinf);
     }
 
     tsize += b.Size;
@@ -258,48 +878,174 @@ func parseVideoSampleDesc(data []byte, entry *SampleEntry) (*VideoSampleDescript
   return &vsd, nil;
 }
 
-func parseESDescriptor(data []byte) (*ESDescriptor, error) {
-  d := ESDescriptor{};
+// readDescriptorHeader returns the tag and payload size of an MPEG-4
+// descriptor along with the number of header bytes. The size uses between
+// one and four bytes, each carrying 7 bits and a continuation flag.
+func readDescriptorHeader(data []byte) (uint8, uint32, int, error) {
+  if (len(data) < 2) {
+    return 0, 0, 0, errors.New("not enough data");
+  }
 
-  d.Tag = data[0];
-  data = data[1:];
+  tag := data[0];
+  var size uint32;
+  n := 1;
 
-  if ((data[0] & 0x80) != 0x00) {
-    data = data[3:];
+  for i := 0; i < 4; i++ {
+    if (n >= len(data)) {
+      return 0, 0, 0, errors.New("not enough data");
+    }
+    size = (size << 7) | uint32(data[n] & 0x7f);
+    n++;
+    if ((data[n - 1] & 0x80) == 0) {
+      break;
+    }
   }
 
-  d.Length = data[0];
-  d.Id = binary.BigEndian.Uint16(data[1:3]);
+  if (uint64(size) > uint64(len(data) - n)) {
+    return 0, 0, 0, errors.New("descriptor size exceeds available data");
+  }
 
-  data = data[4:];
-  tag := data[0];
-  data = data[1:];
+  return tag, size, n, nil;
+}
 
-  if (tag != 0x04) {
-    return nil, errors.New("invalid descriptor tag");
+// walkDescriptors calls fn for every descriptor in data, so that unknown
+// tags are simply passed over.
+func walkDescriptors(data []byte, fn func(tag uint8, payload []byte) error) error {
+  for len(data) > 0 {
+    tag,size,n,err := readDescriptorHeader(data);
+
+    if (err != nil) {
+      return err;
+    }
+
+    err = fn(tag, data[n:n + int(size)]);
+
+    if (err != nil) {
+      return err;
+    }
+
+    data = data[n + int(size):];
   }
 
-  if ((data[0] & 0x80) != 0x00) {
-    data = data[3:];
+  return nil;
+}
+
+func parseDecoderConfigDescriptor(data []byte) (*DecoderConfigDescriptor, error) {
+  dcd := DecoderConfigDescriptor{};
+
+  if (len(data) < 13) {
+    return nil, errors.New("not enough data");
   }
 
-  len := data[0];
-  data = data[14:];
-  tag = data[0];
-  data = data[1:];
+  dcd.ObjectTypeIndication = data[0];
+  dcd.StreamType = data[1] >> 2;
+  dcd.UpStream = (data[1] & 0x02) != 0;
+  dcd.BufferSizeDB = binary.BigEndian.Uint32(data[1:5]) & 0xffffff;
+  dcd.MaxBitrate = binary.BigEndian.Uint32(data[5:9]);
+  dcd.AvgBitrate = binary.BigEndian.Uint32(data[9:13]);
+
+  err := walkDescriptors(data[13:], func(tag uint8, payload []byte) error {
+    if (tag == MP4_DEC_SPECIFIC_INFO_TAG) {
+      dcd.DecoderSpecificInfo = make([]byte, len(payload));
+      copy(dcd.DecoderSpecificInfo, payload);
+    }
+    return nil;
+  });
+
+  if (err != nil) {
+    return nil, err;
+  }
+
+  return &dcd, nil;
+}
+
+func parseESDescriptor(data []byte) (*ESDescriptor, error) {
+  d := ESDescriptor{};
+
+  tag,size,n,err := readDescriptorHeader(data);
 
-  if (tag != 0x05) {
+  if (err != nil) {
+    return nil, err;
+  }
+
+  if (tag != MP4_ES_DESCR_TAG) {
     return nil, errors.New("invalid descriptor tag");
   }
 
-  if ((data[0] & 0x80) != 0x00) {
-    data = data[3:];
+  d.Tag = tag;
+  d.Length = size;
+  data = data[n:n + int(size)];
+
+  if (len(data) < 3) {
+    return nil, errors.New("not enough data");
   }
 
-  len = data[0];
-  data = data[1:];
-  d.Config = make([]byte, len);
-  copy(d.Config, data[:len]);
+  d.Id = binary.BigEndian.Uint16(data[0:2]);
+  d.StreamDependence = (data[2] & 0x80) != 0;
+  d.URLFlag = (data[2] & 0x40) != 0;
+  d.OCRStream = (data[2] & 0x20) != 0;
+  d.StreamPriority = data[2] & 0x1f;
+  data = data[3:];
+
+  if (d.StreamDependence) {
+    if (len(data) < 2) {
+      return nil, errors.New("not enough data");
+    }
+    d.DependsOnId = binary.BigEndian.Uint16(data[0:2]);
+    data = data[2:];
+  }
+
+  if (d.URLFlag) {
+    if (len(data) < 1 || len(data) < 1 + int(data[0])) {
+      return nil, errors.New("not enough data");
+    }
+    d.URL = string(data[1:1 + int(data[0])]);
+    data = data[1 + int(data[0]):];
+  }
+
+  if (d.OCRStream) {
+    if (len(data) < 2) {
+      return nil, errors.New("not enough data");
+    }
+    d.OCRId = binary.BigEndian.Uint16(data[0:2]);
+    data = data[2:];
+  }
+
+  err = walkDescriptors(data, func(tag uint8, payload []byte) error {
+    switch (tag) {
+    case MP4_DECODER_CONFIG_DESCR_TAG:
+      dcd,err := parseDecoderConfigDescriptor(payload);
+      if (err != nil) {
+        return err;
+      }
+      d.DecoderConfig = *dcd;
+      d.ObjectType = dcd.ObjectTypeIndication;
+      d.Config = dcd.DecoderSpecificInfo;
+      if (d.ObjectType == 0x40 && len(d.Config) > 0) {
+        asc,err := ParseAudioSpecificConfig(d.Config);
+        if (err != nil) {
+          fmt.Println(err);
+        }
+        d.AudioConfig = asc;
+      }
+      if (d.ObjectType == 0x20 && len(d.Config) > 0) {
+        vc,err := ParseMPEG4VisualConfig(d.Config);
+        if (err != nil) {
+          fmt.Println(err);
+        }
+        d.VisualConfig = vc;
+      }
+    case MP4_SL_CONFIG_DESCR_TAG:
+      if (len(payload) > 0) {
+        d.SLConfig.Predefined = payload[0];
+      }
+    }
+    return nil;
+  });
+
+  if (err != nil) {
+    return nil, err;
+  }
 
   return &d, nil;
 }
@@ -320,30 +1066,481 @@ func parseElementaryStreamDescBox(data []byte, b *Box) (*ElementaryStreamDescBox
   return &esds, nil;
 }
 
-func parseSoundSampleDesc(data []byte, entry *SampleEntry) (*SoundSampleDescription, error) {
-  ssd := SoundSampleDescription{};
-  ssd.Channels = binary.BigEndian.Uint16(data[8:10]);
-  ssd.SampleSize = binary.BigEndian.Uint16(data[10:12]);
-  fixed := binary.BigEndian.Uint32(data[16:20]);
-  ssd.SampleRate = float32(fixed) / float32(math.Pow(2, 16));
-
-  tsize := entry.Box.headerSize + 8 + 20;
+func parseH263SpecificBox(data []byte, b *Box) (*H263SpecificBox, error) {
+  if (len(data) < 7) {
+    return nil, errors.New("invalid d263 box");
+  }
 
-  data = data[20:];
+  d263 := H263SpecificBox{Box: *b};
+  d263.Vendor = string(data[0:4]);
+  d263.DecoderVersion = data[4];
+  d263.Level = data[5];
+  d263.Profile = data[6];
 
-  for {
-    b,err := parseBox(data);
+  err := iterBoxes(data[7:], func(b *Box, data []byte) {
+    if (b.Type == "bitr" && len(data) >= 8) {
+      d263.AvgBitrate = binary.BigEndian.Uint32(data[0:4]);
+      d263.MaxBitrate = binary.BigEndian.Uint32(data[4:8]);
+    }
+  });
+
+  if (err != nil) {
+    return nil, err;
+  }
+
+  return &d263, nil;
+}
+
+func parseAMRSpecificBox(data []byte, b *Box) (*AMRSpecificBox, error) {
+  if (len(data) < 9) {
+    return nil, errors.New("invalid damr box");
+  }
+
+  damr := AMRSpecificBox{Box: *b};
+  damr.Vendor = string(data[0:4]);
+  damr.DecoderVersion = data[4];
+  damr.ModeSet = binary.BigEndian.Uint16(data[5:7]);
+  damr.ModeChangePeriod = data[7];
+  damr.FramesPerSample = data[8];
+
+  return &damr, nil;
+}
+
+func parsePCMConfigBox(data []byte, b *Box) (*PCMConfigBox, error) {
+  if (len(data) < 6) {
+    return nil, errors.New("invalid pcmC box");
+  }
+
+  fb,_ := parseFullBox(data, b);
+  pcmc := PCMConfigBox{Box: *fb};
+  pcmc.FormatFlags = data[4];
+  pcmc.SampleSize = data[5];
+
+  return &pcmc, nil;
+}
+
+// LittleEndian reports whether the PCM samples are stored little endian.
+func (pcmc PCMConfigBox) LittleEndian() bool {
+  return (pcmc.FormatFlags & 0x01) != 0;
+}
+
+// parseDecompressionParamBox decodes the QuickTime wave atom. The codec
+// configuration it wraps (esds for MPEG-4 audio) is added to the entry
+// extensions as if it was found directly in the sample entry.
+func parseDecompressionParamBox(data []byte, b *Box, entry *SampleEntry) (*DecompressionParamBox, error) {
+  wave := DecompressionParamBox{Box: *b};
+
+  err := walkBoxes(data, "                ", func(b *Box, data []byte) {
+    switch (b.Type) {
+    case "frma":
+      if (len(data) >= 4) {
+        wave.OriginalFormat = string(data[0:4]);
+      }
+    case "enda":
+      if (len(data) >= 2) {
+        wave.LittleEndian = binary.BigEndian.Uint16(data[0:2]) != 0;
+      }
+    case "esds":
+      esds,_ := parseElementaryStreamDescBox(data, b);
+      entry.Extensions = append(entry.Extensions, *esds);
+    }
+  });
+
+  if (err != nil) {
+    return nil, err;
+  }
+
+  return &wave, nil;
+}
+
+func parseChannelLayoutBox(data []byte, b *Box) (*ChannelLayoutBox, error) {
+  if (len(data) < 16) {
+    return nil, errors.New("invalid chan box");
+  }
+
+  fb,_ := parseFullBox(data, b);
+  chn := ChannelLayoutBox{Box: *fb};
+
+  data = data[4:];
+
+  chn.LayoutTag = binary.BigEndian.Uint32(data[0:4]);
+  chn.Bitmap = binary.BigEndian.Uint32(data[4:8]);
+  n := binary.BigEndian.Uint32(data[8:12]);
+
+  data = data[12:];
+
+  if (uint64(len(data)) < uint64(n) * 20) {
+    return nil, errors.New("invalid chan box");
+  }
+
+  for i := 0; i < int(n); i++ {
+    cd := ChannelDescription{};
+    cd.Label = binary.BigEndian.Uint32(data[0:4]);
+    cd.Flags = binary.BigEndian.Uint32(data[4:8]);
+    for j := 0; j < 3; j++ {
+      cd.Coordinates[j] = math.Float32frombits(binary.BigEndian.Uint32(data[8 + j * 4:]));
+    }
+    chn.Descriptions = append(chn.Descriptions, cd);
+    data = data[20:];
+  }
+
+  return &chn, nil;
+}
+
+func parseTimecodeSampleDesc(data []byte) (*TimecodeSampleDescription, error) {
+  if (len(data) < 18) {
+    return nil, errors.New("invalid timecode sample entry");
+  }
+
+  tsd := TimecodeSampleDescription{};
+  tsd.Flags = binary.BigEndian.Uint32(data[4:8]);
+  tsd.Timescale = binary.BigEndian.Uint32(data[8:12]);
+  tsd.FrameDuration = binary.BigEndian.Uint32(data[12:16]);
+  tsd.NumberOfFrames = data[16];
+
+  return &tsd, nil;
+}
+
+func parseOpusSpecificBox(data []byte, b *Box) (*OpusSpecificBox, error) {
+  dops := OpusSpecificBox{Box: *b};
+
+  dops.Version = data[0];
+  dops.OutputChannelCount = data[1];
+  dops.PreSkip = binary.BigEndian.Uint16(data[2:4]);
+  dops.InputSampleRate = binary.BigEndian.Uint32(data[4:8]);
+  dops.OutputGain = int16(binary.BigEndian.Uint16(data[8:10]));
+  dops.ChannelMappingFamily = data[10];
+
+  if (dops.ChannelMappingFamily != 0) {
+    dops.StreamCount = data[11];
+    dops.CoupledCount = data[12];
+    dops.ChannelMapping = make([]uint8, dops.OutputChannelCount);
+    copy(dops.ChannelMapping, data[13:13 + int(dops.OutputChannelCount)]);
+  }
+
+  return &dops, nil;
+}
+
+func parseFLACStreamInfo(data []byte) (*FLACStreamInfo, error) {
+  si := FLACStreamInfo{};
+  br := NewBitReader(data);
+
+  si.MinBlockSize = uint16(br.ReadBits(16));
+  si.MaxBlockSize = uint16(br.ReadBits(16));
+  si.MinFrameSize = uint32(br.ReadBits(24));
+  si.MaxFrameSize = uint32(br.ReadBits(24));
+  si.SampleRate = uint32(br.ReadBits(20));
+  si.Channels = uint8(br.ReadBits(3)) + 1;
+  si.BitsPerSample = uint8(br.ReadBits(5)) + 1;
+  si.TotalSamples = br.ReadBits(36);
+
+  if (br.Err() != nil || len(data) < 34) {
+    return nil, errors.New("not enough data");
+  }
+
+  copy(si.MD5[:], data[18:34]);
+
+  return &si, nil;
+}
+
+func parseFLACSpecificBox(data []byte, b *Box) (*FLACSpecificBox, error) {
+  fb,_ := parseFullBox(data, b);
+  dfla := FLACSpecificBox{Box: *fb};
+  data = data[4:];
+
+  for len(data) >= 4 {
+    block := FLACMetadataBlock{};
+    block.Last = (data[0] & 0x80) != 0;
+    block.Type = data[0] & 0x7f;
+
+    size := int(binary.BigEndian.Uint32(data[0:4]) & 0xffffff);
+    data = data[4:];
+
+    if (size > len(data)) {
+      return nil, errors.New("not enough data");
+    }
+
+    block.Data = make([]byte, size);
+    copy(block.Data, data[:size]);
+    data = data[size:];
+
+    if (block.Type == 0) {
+      si,err := parseFLACStreamInfo(block.Data);
+      if (err != nil) {
+        return nil, err;
+      }
+      block.StreamInfo = si;
+    }
+
+    dfla.Blocks = append(dfla.Blocks, block);
+
+    if (block.Last) {
+      break;
+    }
+  }
+
+  return &dfla, nil;
+}
+
+func parseAC3SpecificBox(data []byte, b *Box) (*AC3SpecificBox, error) {
+  dac3 := AC3SpecificBox{Box: *b};
+  br := NewBitReader(data);
+
+  dac3.Fscod = uint8(br.ReadBits(2));
+  dac3.Bsid = uint8(br.ReadBits(5));
+  dac3.Bsmod = uint8(br.ReadBits(3));
+  dac3.Acmod = uint8(br.ReadBits(3));
+  dac3.Lfeon = br.ReadFlag();
+  dac3.BitRateCode = uint8(br.ReadBits(5));
+
+  if (br.Err() != nil) {
+    return nil, br.Err();
+  }
+
+  return &dac3, nil;
+}
+
+func parseEC3SpecificBox(data []byte, b *Box) (*EC3SpecificBox, error) {
+  dec3 := EC3SpecificBox{Box: *b};
+  br := NewBitReader(data);
+
+  dec3.DataRate = uint16(br.ReadBits(13));
+  n := int(br.ReadBits(3)) + 1;
+
+  for i := 0; i < n; i++ {
+    sub := EC3Substream{};
+    sub.Fscod = uint8(br.ReadBits(2));
+    sub.Bsid = uint8(br.ReadBits(5));
+    br.Skip(1);
+    sub.Asvc = br.ReadFlag();
+    sub.Bsmod = uint8(br.ReadBits(3));
+    sub.Acmod = uint8(br.ReadBits(3));
+    sub.Lfeon = br.ReadFlag();
+    br.Skip(3);
+    sub.NumDepSub = uint8(br.ReadBits(4));
+    if (sub.NumDepSub > 0) {
+      sub.ChanLoc = uint16(br.ReadBits(9));
+    } else {
+      br.Skip(1);
+    }
+    dec3.Substreams = append(dec3.Substreams, sub);
+  }
+
+  if (br.Err() != nil) {
+    return nil, br.Err();
+  }
+
+  // the Atmos (JOC) extension is optional and trails the substreams
+  if (br.BitsLeft() >= 16) {
+    br.Skip(7);
+    dec3.JOC = br.ReadFlag();
+    dec3.JOCComplexityIndex = uint8(br.ReadBits(8));
+  }
+
+  return &dec3, nil;
+}
+
+func parseAC4SpecificBox(data []byte, b *Box) (*AC4SpecificBox, error) {
+  dac4 := AC4SpecificBox{Box: *b};
+  br := NewBitReader(data);
+
+  dac4.DSIVersion = uint8(br.ReadBits(3));
+  dac4.BitstreamVersion = uint8(br.ReadBits(7));
+  dac4.FsIndex = uint8(br.ReadBits(1));
+  dac4.FrameRateIndex = uint8(br.ReadBits(4));
+  dac4.NumPresentations = uint16(br.ReadBits(9));
+
+  if (dac4.BitstreamVersion > 1) {
+    if (br.ReadFlag()) {
+      br.Skip(16);
+      if (br.ReadFlag()) {
+        br.Skip(128);
+      }
+    }
+  }
+
+  // ac4_bitrate_dsi
+  br.Skip(2 + 32 + 32);
+  br.Skip(br.BitsLeft() % 8);
+
+  // only the first presentation is looked at, for the codec string
+  if (dac4.NumPresentations > 0) {
+    dac4.PresentationVersion = uint8(br.ReadBits(8));
+    presBytes := br.ReadBits(8);
+    if (presBytes == 255) {
+      br.Skip(16);
+    }
+    // both ac4_presentation_v0_dsi and ac4_presentation_v1_dsi start with
+    // the presentation config, followed by mdcompat unless it is 6
+    if (dac4.PresentationVersion <= 2 && br.ReadBits(5) != 0x06) {
+      dac4.MdCompat = uint8(br.ReadBits(3));
+    }
+  }
+
+  if (br.Err() != nil) {
+    return nil, br.Err();
+  }
+
+  dac4.Data = make([]byte, len(data));
+  copy(dac4.Data, data);
+
+  return &dac4, nil;
+}
+
+// isBoxStart reports whether data looks like it begins with a box header.
+func isBoxStart(data []byte) bool {
+  if (len(data) < BOX_HDR_SZ) {
+    return false;
+  }
+
+  size := binary.BigEndian.Uint32(data[0:4]);
+
+  if (size < BOX_HDR_SZ || uint64(size) > uint64(len(data))) {
+    return false;
+  }
+
+  for _,c := range data[4:8] {
+    if (c < 0x20 || c > 0x7e) {
+      return false;
+    }
+  }
+
+  return true;
+}
+
+func parseSoundSampleDesc(data []byte, entry *SampleEntry) (*SoundSampleDescription, error) {
+  if (len(data) < 20) {
+    return nil, errors.New("invalid sound sample entry");
+  }
+
+  ssd := SoundSampleDescription{};
+  ssd.Version = binary.BigEndian.Uint16(data[0:2]);
+  ssd.Revision = binary.BigEndian.Uint16(data[2:4]);
+  if (binary.BigEndian.Uint32(data[4:8]) != 0) {
+    ssd.Vendor = string(data[4:8]);
+  }
+  ssd.Channels = binary.BigEndian.Uint16(data[8:10]);
+  ssd.SampleSize = binary.BigEndian.Uint16(data[10:12]);
+  ssd.CompressionID = int16(binary.BigEndian.Uint16(data[12:14]));
+  ssd.PacketSize = binary.BigEndian.Uint16(data[14:16]);
+  fixed := binary.BigEndian.Uint32(data[16:20]);
+  ssd.SampleRate = float32(fixed) / float32(math.Pow(2, 16));
+
+  hlen := 20;
+
+  switch {
+  // the ISO AudioSampleEntryV1 shares the version number but not the
+  // extra fields, it has its extensions right after the common ones
+  case ssd.Version == 1 && len(data) >= 36 && !isBoxStart(data[20:]):
+    ssd.SamplesPerPacket = binary.BigEndian.Uint32(data[20:24]);
+    ssd.BytesPerPacket = binary.BigEndian.Uint32(data[24:28]);
+    ssd.BytesPerFrame = binary.BigEndian.Uint32(data[28:32]);
+    ssd.BytesPerSample = binary.BigEndian.Uint32(data[32:36]);
+    hlen = 36;
+  case ssd.Version == 2:
+    if (len(data) < 56) {
+      return nil, errors.New("invalid sound sample entry");
+    }
+    ssd.SampleRate = float32(math.Float64frombits(binary.BigEndian.Uint64(data[24:32])));
+    ssd.Channels = uint16(binary.BigEndian.Uint32(data[32:36]));
+    ssd.SampleSize = uint16(binary.BigEndian.Uint32(data[40:44]));
+    ssd.FormatSpecificFlags = binary.BigEndian.Uint32(data[44:48]);
+    ssd.ConstBytesPerAudioPacket = binary.BigEndian.Uint32(data[48:52]);
+    ssd.ConstLPCMFramesPerAudioPacket = binary.BigEndian.Uint32(data[52:56]);
+    hlen = 56;
+  }
+
+  tsize := entry.Box.headerSize + 8 + uint64(hlen);
+
+  data = data[hlen:];
+
+  // a 4 byte terminator may follow the last extension
+  for tsize + BOX_HDR_SZ <= entry.Box.Size {
+    b,err := parseBox(data);
 
     if (err != nil) {
       return nil, err;
     }
 
+    if (b.Size < b.headerSize || tsize + b.Size > entry.Box.Size) {
+      return nil, errors.New("invalid box size");
+    }
+
     fmt.Println("              -", b.Type);
 
     switch (b.Type) {
     case "esds":
       esds,_ := parseElementaryStreamDescBox(data[b.headerSize:b.Size], b);
       entry.Extensions = append(entry.Extensions, *esds);
+    case "btrt":
+      btrt,_ := parseBitRateBox(data[b.headerSize:b.Size], b);
+      entry.Extensions = append(entry.Extensions, *btrt);
+    case "dOps":
+      dops,_ := parseOpusSpecificBox(data[b.headerSize:b.Size], b);
+      entry.Extensions = append(entry.Extensions, *dops);
+    case "dfLa":
+      dfla,err := parseFLACSpecificBox(data[b.headerSize:b.Size], b);
+      if (err != nil) {
+        fmt.Println(err);
+        break;
+      }
+      entry.Extensions = append(entry.Extensions, *dfla);
+    case "dac3":
+      dac3,err := parseAC3SpecificBox(data[b.headerSize:b.Size], b);
+      if (err != nil) {
+        fmt.Println(err);
+        break;
+      }
+      entry.Extensions = append(entry.Extensions, *dac3);
+    case "dec3":
+      dec3,err := parseEC3SpecificBox(data[b.headerSize:b.Size], b);
+      if (err != nil) {
+        fmt.Println(err);
+        break;
+      }
+      entry.Extensions = append(entry.Extensions, *dec3);
+    case "dac4":
+      dac4,err := parseAC4SpecificBox(data[b.headerSize:b.Size], b);
+      if (err != nil) {
+        fmt.Println(err);
+        break;
+      }
+      entry.Extensions = append(entry.Extensions, *dac4);
+    case "damr":
+      damr,err := parseAMRSpecificBox(data[b.headerSize:b.Size], b);
+      if (err != nil) {
+        fmt.Println(err);
+        break;
+      }
+      entry.Extensions = append(entry.Extensions, *damr);
+    case "pcmC":
+      pcmc,err := parsePCMConfigBox(data[b.headerSize:b.Size], b);
+      if (err != nil) {
+        fmt.Println(err);
+        break;
+      }
+      entry.Extensions = append(entry.Extensions, *pcmc);
+    case "wave":
+      wave,err := parseDecompressionParamBox(data[b.headerSize:b.Size], b, entry);
+      if (err != nil) {
+        fmt.Println(err);
+        break;
+      }
+      entry.Extensions = append(entry.Extensions, *wave);
+    case "chan":
+      chn,err := parseChannelLayoutBox(data[b.headerSize:b.Size], b);
+      if (err != nil) {
+        fmt.Println(err);
+        break;
+      }
+      entry.Extensions = append(entry.Extensions, *chn);
+    case "sinf":
+      sinf,err := parseProtectionSchemeInfoBox(data[b.headerSize:b.Size], b);
+      if (err != nil) {
+        fmt.Println(err);
+        break;
+      }
+      entry.Extensions = append(entry.Extensions, *sinf);
     }
 
     tsize += b.Size;
@@ -358,179 +1555,775 @@ func parseSoundSampleDesc(data []byte, entry *SampleEntry) (*SoundSampleDescript
   return &ssd, nil;
 }
 
-func parseSampleDescBox(data []byte, b *Box) (*SampleDescriptionBox, error) {
+func parseTextStyleRecord(data []byte) TextStyleRecord {
+  sr := TextStyleRecord{};
+  sr.StartChar = binary.BigEndian.Uint16(data[0:2]);
+  sr.EndChar = binary.BigEndian.Uint16(data[2:4]);
+  sr.FontID = binary.BigEndian.Uint16(data[4:6]);
+  sr.FaceStyleFlags = data[6];
+  sr.FontSize = data[7];
+  copy(sr.TextColor[:], data[8:12]);
+  return sr;
+}
+
+func parseFontTableBox(data []byte, b *Box) (*FontTableBox, error) {
+  ftab := FontTableBox{Box: *b};
+
+  ftab.EntryCount = binary.BigEndian.Uint16(data[0:2]);
+
+  data = data[2:];
+
+  for i := 0; i < int(ftab.EntryCount); i++ {
+    if (len(data) < 3 || len(data) < 3 + int(data[2])) {
+      return nil, errors.New("invalid font table");
+    }
+    fr := FontRecord{};
+    fr.FontID = binary.BigEndian.Uint16(data[0:2]);
+    fr.Name = string(data[3:3 + int(data[2])]);
+    ftab.Fonts = append(ftab.Fonts, fr);
+    data = data[3 + int(data[2]):];
+  }
+
+  return &ftab, nil;
+}
+
+// parseEntryExtensions decodes the boxes following the fields of the timed
+// text and metadata sample entries.
+func parseEntryExtensions(data []byte, entry *SampleEntry) error {
+  return walkBoxes(data, "              ", func(b *Box, data []byte) {
+    switch (b.Type) {
+    case "ftab":
+      ftab,err := parseFontTableBox(data, b);
+      if (err != nil) {
+        fmt.Println(err);
+        return;
+      }
+      entry.Extensions = append(entry.Extensions, *ftab);
+    case "vttC":
+      entry.Extensions = append(entry.Extensions, WebVTTConfigBox{Box: *b, Config: string(data)});
+    case "vlab":
+      entry.Extensions = append(entry.Extensions, WebVTTSourceLabelBox{Box: *b, SourceLabel: string(data)});
+    case "btrt":
+      btrt,_ := parseBitRateBox(data, b);
+      entry.Extensions = append(entry.Extensions, *btrt);
+    case "txtC":
+      fb,_ := parseFullBox(data, b);
+      txtc := TextConfigBox{Box: *fb};
+      txtc.Config,_ = readCString(data[4:]);
+      entry.Extensions = append(entry.Extensions, txtc);
+    case "uri ":
+      fb,_ := parseFullBox(data, b);
+      uri := URIBox{Box: *fb};
+      uri.URI,_ = readCString(data[4:]);
+      entry.Extensions = append(entry.Extensions, uri);
+    case "uriI":
+      fb,_ := parseFullBox(data, b);
+      entry.Extensions = append(entry.Extensions, URIInitBox{Box: *fb, Data: data[4:]});
+    }
+  });
+}
+
+func parseTextSampleDesc(data []byte, entry *SampleEntry) (*TextSampleDescription, error) {
+  if (len(data) < 30) {
+    return nil, errors.New("invalid text sample entry");
+  }
+
+  tsd := TextSampleDescription{};
+  tsd.DisplayFlags = binary.BigEndian.Uint32(data[0:4]);
+  tsd.HorizontalJustification = int8(data[4]);
+  tsd.VerticalJustification = int8(data[5]);
+  copy(tsd.BackgroundColor[:], data[6:10]);
+  tsd.DefaultTextBox.Top = int16(binary.BigEndian.Uint16(data[10:12]));
+  tsd.DefaultTextBox.Left = int16(binary.BigEndian.Uint16(data[12:14]));
+  tsd.DefaultTextBox.Bottom = int16(binary.BigEndian.Uint16(data[14:16]));
+  tsd.DefaultTextBox.Right = int16(binary.BigEndian.Uint16(data[16:18]));
+  tsd.DefaultStyle = parseTextStyleRecord(data[18:30]);
+
+  err := parseEntryExtensions(data[30:], entry);
+
+  if (err != nil) {
+    return nil, err;
+  }
+
+  return &tsd, nil;
+}
+
+func parseXMLSubtitleSampleDesc(data []byte, entry *SampleEntry) (*XMLSubtitleSampleDescription, error) {
+  xsd := XMLSubtitleSampleDescription{};
+
+  xsd.Namespace,data = readCString(data);
+  xsd.SchemaLocation,data = readCString(data);
+  xsd.AuxiliaryMimeTypes,data = readCString(data);
+
+  err := parseEntryExtensions(data, entry);
+
+  if (err != nil) {
+    return nil, err;
+  }
+
+  return &xsd, nil;
+}
+
+func parseTextMetadataSampleDesc(data []byte, entry *SampleEntry) (*TextMetadataSampleDescription, error) {
+  tmd := TextMetadataSampleDescription{};
+
+  tmd.ContentEncoding,data = readCString(data);
+  tmd.MimeFormat,data = readCString(data);
+
+  err := parseEntryExtensions(data, entry);
+
+  if (err != nil) {
+    return nil, err;
+  }
+
+  return &tmd, nil;
+}
+
+func parseXMLMetadataSampleDesc(data []byte, entry *SampleEntry) (*XMLMetadataSampleDescription, error) {
+  xmd := XMLMetadataSampleDescription{};
+
+  xmd.ContentEncoding,data = readCString(data);
+  xmd.Namespace,data = readCString(data);
+  xmd.SchemaLocation,data = readCString(data);
+
+  err := parseEntryExtensions(data, entry);
+
+  if (err != nil) {
+    return nil, err;
+  }
+
+  return &xmd, nil;
+}
+
+func parseSampleDescBox(data []byte, b *Box) (*SampleDescriptionBox, error) {
+  if (len(data) < 8) {
+    return nil, errors.New("invalid stsd box");
+  }
+
+  fb,_ := parseFullBox(data, b);
+  sdb := SampleDescriptionBox{Box: *fb};
+
+  data = data[4:];
+
+  sdb.EntryCount = binary.BigEndian.Uint32(data[0:4]);
+
+  data = data[4:];
+
+  tsize := b.headerSize + 4 + 4;
+
+  for i := 0; i < int(sdb.EntryCount); i++ {
+    b,err := parseBox(data);
+
+    if (err != nil) {
+      return nil, err;
+    }
+
+    fmt.Println("            -", b.Type);
+
+    if (b.Size < b.headerSize + 8 || b.Size > uint64(len(data))) {
+      return nil, errors.New("invalid sample entry size");
+    }
+
+    entry := SampleEntry{Box: *b};
+
+    edata := data[b.headerSize:b.Size];
+
+    entry.DataRefIndex = binary.BigEndian.Uint16(edata[6:8]);
+
+    edata = edata[8:];
+
+    switch (b.Type) {
+    case "avc1", "avc2", "avc3", "avc4", "hvc1", "hev1", "av01", "vp08", "vp09",
+      "dvh1", "dvhe", "dva1", "dvav", "dav1", "mp4v", "s263", "encv":
+      vsd,err := parseVideoSampleDesc(edata, &entry);
+      if (err != nil) {
+        fmt.Println(err);
+        break;
+      }
+      entry.SampleDesc = *vsd;
+    case "mp4a", "Opus", "fLaC", "ac-3", "ec-3", "ac-4", "samr", "sawb",
+      "ulaw", "alaw", "lpcm", "ipcm", "fpcm", "twos", "sowt", "in24", "in32",
+      "fl32", "fl64", "raw ", "ima4", "enca":
+      ssd,err := parseSoundSampleDesc(edata, &entry);
+      if (err != nil) {
+        fmt.Println(err);
+        break;
+      }
+      entry.SampleDesc = *ssd;
+    case "tx3g":
+      tsd,err := parseTextSampleDesc(edata, &entry);
+      if (err != nil) {
+        fmt.Println(err);
+        break;
+      }
+      entry.SampleDesc = *tsd;
+    case "stpp":
+      xsd,err := parseXMLSubtitleSampleDesc(edata, &entry);
+      if (err != nil) {
+        fmt.Println(err);
+        break;
+      }
+      entry.SampleDesc = *xsd;
+    case "tmcd":
+      tsd,err := parseTimecodeSampleDesc(edata);
+      if (err != nil) {
+        fmt.Println(err);
+        break;
+      }
+      entry.SampleDesc = *tsd;
+    case "mett":
+      tmd,err := parseTextMetadataSampleDesc(edata, &entry);
+      if (err != nil) {
+        fmt.Println(err);
+        break;
+      }
+      entry.SampleDesc = *tmd;
+    case "metx":
+      xmd,err := parseXMLMetadataSampleDesc(edata, &entry);
+      if (err != nil) {
+        fmt.Println(err);
+        break;
+      }
+      entry.SampleDesc = *xmd;
+    case "wvtt", "c608", "urim":
+      // no fields beyond the SampleEntry ones
+      err := parseEntryExtensions(edata, &entry);
+      if (err != nil) {
+        fmt.Println(err);
+      }
+    }
+
+    sdb.Entries = append(sdb.Entries, entry);
+
+    tsize += b.Size;
+
+    if (tsize == sdb.Box.Box.Size) {
+      break;
+    }
+
+    data = data[b.Size:];
+  }
+
+  return &sdb, nil;
+}
+
+func parseTimeToSampleBox(data []byte, b *Box) (*TimeToSampleBox, error) {
+  fb,_ := parseFullBox(data, b);
+  ttsb := TimeToSampleBox{Box: *fb};
+  data = data[4:];
+
+  ttsb.EntryCount = binary.BigEndian.Uint32(data[0:4]);
+
+  data = data[4:];
+
+  ttsb.SampleCount = make([]uint32, ttsb.EntryCount);
+  ttsb.SampleDelta = make([]uint32, ttsb.EntryCount);
+
+  for i := 0; i < int(ttsb.EntryCount); i++ {
+    ttsb.SampleCount[i] = binary.BigEndian.Uint32(data[0:4]);
+    ttsb.SampleDelta[i] = binary.BigEndian.Uint32(data[4:8]);
+    data = data[8:];
+  }
+
+  return &ttsb, nil;
+}
+
+func parseCompTimeToSampleBox(data []byte, b *Box) (*CompTimeToSampleBox, error) {
+  fb,_ := parseFullBox(data, b);
+  ctsb := CompTimeToSampleBox{Box: *fb};
+  data = data[4:];
+
+  ctsb.EntryCount = binary.BigEndian.Uint32(data[0:4]);
+
+  data = data[4:];
+
+  ctsb.SampleCount = make([]int32, ctsb.EntryCount);
+  ctsb.SampleOffset = make([]int32, ctsb.EntryCount);
+
+  for i := 0; i < int(ctsb.EntryCount); i++ {
+    ctsb.SampleCount[i] = int32(binary.BigEndian.Uint32(data[0:4]));
+    ctsb.SampleOffset[i] = int32(binary.BigEndian.Uint32(data[4:8]));
+    data = data[8:];
+  }
+
+  return &ctsb, nil;
+}
+
+func parseSyncSampleBox(data []byte, b *Box) (*SyncSampleBox, error) {
+  fb,_ := parseFullBox(data, b);
+  ssb := SyncSampleBox{Box: *fb};
+  data = data[4:];
+
+  ssb.EntryCount = binary.BigEndian.Uint32(data[0:4]);
+
+  data = data[4:];
+
+  ssb.SampleNumber = make([]uint32, ssb.EntryCount);
+
+  for i := 0; i < int(ssb.EntryCount); i++ {
+    ssb.SampleNumber[i] = binary.BigEndian.Uint32(data[0:4]);
+    data = data[4:];
+  }
+
+  return &ssb, nil;
+}
+
+func parseSampleToChunkBox(data []byte, b *Box) (*SampleToChunkBox, error) {
+  fb,_ := parseFullBox(data, b);
+  stcb := SampleToChunkBox{Box: *fb};
+  data = data[4:];
+
+  stcb.EntryCount = binary.BigEndian.Uint32(data[0:4]);
+
+  data = data[4:];
+
+  stcb.FirstChunk = make([]int32, stcb.EntryCount);
+  stcb.SamplesPerChunk = make([]int32, stcb.EntryCount);
+  stcb.SampleDescIndex = make([]int32, stcb.EntryCount);
+
+  for i := 0; i < int(stcb.EntryCount); i++ {
+    stcb.FirstChunk[i] = int32(binary.BigEndian.Uint32(data[0:4]));
+    stcb.SamplesPerChunk[i] = int32(binary.BigEndian.Uint32(data[4:8]));
+    stcb.SampleDescIndex[i] = int32(binary.BigEndian.Uint32(data[8:12]));
+    data = data[12:];
+  }
+
+  return &stcb, nil;
+}
+
+func parseSampleSizeBox(data []byte, b *Box) (*SampleSizeBox, error) {
+  fb,_ := parseFullBox(data, b);
+  ssb := SampleSizeBox{Box: *fb};
+  data = data[4:];
+
+  ssb.SampleSize = binary.BigEndian.Uint32(data[0:4]);
+  ssb.SampleCount = binary.BigEndian.Uint32(data[4:8]);
+
+  data = data[8:];
+
+  if (ssb.SampleSize == 0) {
+    ssb.EntrySize = make([]uint32, ssb.SampleCount);
+    for i := 0; i < int(ssb.SampleCount); i++ {
+      ssb.EntrySize[i] = binary.BigEndian.Uint32(data[0:4]);
+      data = data[4:];
+    }
+  }
+
+  return &ssb, nil;
+}
+
+func parseChunkOffsetBox(data []byte, b *Box) (*ChunkOffsetBox, error) {
+  fb,_ := parseFullBox(data, b);
+  cob := ChunkOffsetBox{Box: *fb};
+  data = data[4:];
+
+  cob.EntryCount = binary.BigEndian.Uint32(data[0:4]);
+
+  data = data[4:];
+
+  cob.ChunkOffset = make([]uint32, cob.EntryCount);
+  for i := 0; i < int(cob.EntryCount); i++ {
+    cob.ChunkOffset[i] = binary.BigEndian.Uint32(data[0:4]);
+    data = data[4:];
+  }
+
+  return &cob, nil;
+}
+
+func parseChunkLargeOffsetBox(data []byte, b *Box) (*ChunkLargeOffsetBox, error) {
+  if (len(data) < 8) {
+    return nil, errors.New("invalid co64 box");
+  }
+
+  fb,_ := parseFullBox(data, b);
+  cob := ChunkLargeOffsetBox{Box: *fb};
+
+  cob.EntryCount = binary.BigEndian.Uint32(data[4:8]);
+
+  data = data[8:];
+
+  if (uint64(len(data)) < uint64(cob.EntryCount) * 8) {
+    return nil, errors.New("invalid co64 box");
+  }
+
+  cob.ChunkOffset = make([]uint64, cob.EntryCount);
+  for i := 0; i < int(cob.EntryCount); i++ {
+    cob.ChunkOffset[i] = binary.BigEndian.Uint64(data[i * 8:]);
+  }
+
+  return &cob, nil;
+}
+
+func parseSampleAuxInfoSizesBox(data []byte, b *Box) (*SampleAuxInfoSizesBox, error) {
+  if (len(data) < 4) {
+    return nil, errors.New("invalid saiz box");
+  }
+
   fb,_ := parseFullBox(data, b);
-  sdb := SampleDescriptionBox{Box: *fb};
+  saiz := SampleAuxInfoSizesBox{Box: *fb};
 
   data = data[4:];
 
-  sdb.EntryCount = binary.BigEndian.Uint32(data[0:4]);
+  if ((fb.Flags[2] & 0x01) != 0) {
+    if (len(data) < 8) {
+      return nil, errors.New("invalid saiz box");
+    }
+    saiz.AuxInfoType = string(data[0:4]);
+    saiz.AuxInfoTypeParameter = binary.BigEndian.Uint32(data[4:8]);
+    data = data[8:];
+  }
 
-  data = data[4:];
+  if (len(data) < 5) {
+    return nil, errors.New("invalid saiz box");
+  }
 
-  tsize := b.headerSize + 4 + 4;
+  saiz.DefaultSampleInfoSize = data[0];
+  saiz.SampleCount = binary.BigEndian.Uint32(data[1:5]);
 
-  for i := 0; i < int(sdb.EntryCount); i++ {
-    b,err := parseBox(data);
+  data = data[5:];
 
-    if (err != nil) {
-      return nil, err;
+  if (saiz.DefaultSampleInfoSize == 0) {
+    if (uint64(len(data)) < uint64(saiz.SampleCount)) {
+      return nil, errors.New("invalid saiz box");
     }
+    saiz.SampleInfoSize = data[:saiz.SampleCount];
+  }
 
-    fmt.Println("            -", b.Type);
+  return &saiz, nil;
+}
 
-    entry := SampleEntry{Box: *b};
+func parseSampleAuxInfoOffsetsBox(data []byte, b *Box) (*SampleAuxInfoOffsetsBox, error) {
+  if (len(data) < 4) {
+    return nil, errors.New("invalid saio box");
+  }
 
-    data = data[b.headerSize:];
+  fb,_ := parseFullBox(data, b);
+  saio := SampleAuxInfoOffsetsBox{Box: *fb};
 
-    entry.DataRefIndex = binary.BigEndian.Uint16(data[6:8]);
+  data = data[4:];
 
+  if ((fb.Flags[2] & 0x01) != 0) {
+    if (len(data) < 8) {
+      return nil, errors.New("invalid saio box");
+    }
+    saio.AuxInfoType = string(data[0:4]);
+    saio.AuxInfoTypeParameter = binary.BigEndian.Uint32(data[4:8]);
     data = data[8:];
+  }
 
-    switch (b.Type) {
-    case "avc1":
-      vsd,_ := parseVideoSampleDesc(data, &entry);
-      entry.SampleDesc = *vsd;
-    case "mp4a":
-      ssd,_ := parseSoundSampleDesc(data, &entry);
-      entry.SampleDesc = *ssd;
-    }
+  if (len(data) < 4) {
+    return nil, errors.New("invalid saio box");
+  }
 
-    sdb.Entries = append(sdb.Entries, entry);
+  saio.EntryCount = binary.BigEndian.Uint32(data[0:4]);
 
-    tsize += b.Size;
+  data = data[4:];
 
-    if (tsize == sdb.Box.Box.Size) {
-      break;
-    }
+  size := uint64(4);
 
-    data = data[b.Size:];
+  if (fb.Version > 0) {
+    size = 8;
   }
 
-  return &sdb, nil;
+  if (uint64(len(data)) < uint64(saio.EntryCount) * size) {
+    return nil, errors.New("invalid saio box");
+  }
+
+  saio.Offsets = make([]uint64, saio.EntryCount);
+
+  for i := range saio.Offsets {
+    if (fb.Version > 0) {
+      saio.Offsets[i] = binary.BigEndian.Uint64(data[i * 8:]);
+    } else {
+      saio.Offsets[i] = uint64(binary.BigEndian.Uint32(data[i * 4:]));
+    }
+  }
+
+  return &saio, nil;
 }
 
-func parseTimeToSampleBox(data []byte, b *Box) (*TimeToSampleBox, error) {
-  fb,_ := parseFullBox(data, b);
-  ttsb := TimeToSampleBox{Box: *fb};
-  data = data[4:];
+func parseSampleEncryptionBox(data []byte, b *Box) (*SampleEncryptionBox, error) {
+  if (len(data) < 8) {
+    return nil, errors.New("invalid senc box");
+  }
 
-  ttsb.EntryCount = binary.BigEndian.Uint32(data[0:4]);
+  fb,_ := parseFullBox(data, b);
+  senc := SampleEncryptionBox{Box: *fb};
 
-  data = data[4:];
+  senc.SampleCount = binary.BigEndian.Uint32(data[4:8]);
+  senc.data = data[8:];
 
-  ttsb.SampleCount = make([]uint32, ttsb.EntryCount);
-  ttsb.SampleDelta = make([]uint32, ttsb.EntryCount);
+  return &senc, nil;
+}
 
-  for i := 0; i < int(ttsb.EntryCount); i++ {
-    ttsb.SampleCount[i] = binary.BigEndian.Uint32(data[0:4]);
-    ttsb.SampleDelta[i] = binary.BigEndian.Uint32(data[4:8]);
-    data = data[8:];
+func parseSampleDependencyTypeBox(data []byte, b *Box) (*SampleDependencyTypeBox, error) {
+  if (len(data) < 4) {
+    return nil, errors.New("invalid sdtp box");
   }
 
-  return &ttsb, nil;
+  fb,_ := parseFullBox(data, b);
+  sdtp := SampleDependencyTypeBox{Box: *fb};
+
+  // the sample count comes from stsz
+  sdtp.Entries = data[4:];
+
+  return &sdtp, nil;
 }
 
-func parseCompTimeToSampleBox(data []byte, b *Box) (*CompTimeToSampleBox, error) {
+func parseSampleToGroupBox(data []byte, b *Box) (*SampleToGroupBox, error) {
+  if (len(data) < 12) {
+    return nil, errors.New("invalid sbgp box");
+  }
+
   fb,_ := parseFullBox(data, b);
-  ctsb := CompTimeToSampleBox{Box: *fb};
-  data = data[4:];
+  sbgp := SampleToGroupBox{Box: *fb};
 
-  ctsb.EntryCount = binary.BigEndian.Uint32(data[0:4]);
+  sbgp.GroupingType = string(data[4:8]);
+  data = data[8:];
+
+  if (fb.Version == 1) {
+    sbgp.GroupingTypeParameter = binary.BigEndian.Uint32(data[0:4]);
+    data = data[4:];
+  }
 
+  if (len(data) < 4) {
+    return nil, errors.New("invalid sbgp box");
+  }
+
+  sbgp.EntryCount = binary.BigEndian.Uint32(data[0:4]);
   data = data[4:];
 
-  ctsb.SampleCount = make([]int32, ctsb.EntryCount);
-  ctsb.SampleOffset = make([]int32, ctsb.EntryCount);
+  if (uint64(len(data)) < uint64(sbgp.EntryCount) * 8) {
+    return nil, errors.New("invalid sbgp box");
+  }
 
-  for i := 0; i < int(ctsb.EntryCount); i++ {
-    ctsb.SampleCount[i] = int32(binary.BigEndian.Uint32(data[0:4]));
-    ctsb.SampleOffset[i] = int32(binary.BigEndian.Uint32(data[4:8]));
+  sbgp.SampleCount = make([]uint32, sbgp.EntryCount);
+  sbgp.GroupDescriptionIndex = make([]uint32, sbgp.EntryCount);
+
+  for i := 0; i < int(sbgp.EntryCount); i++ {
+    sbgp.SampleCount[i] = binary.BigEndian.Uint32(data[0:4]);
+    sbgp.GroupDescriptionIndex[i] = binary.BigEndian.Uint32(data[4:8]);
     data = data[8:];
   }
 
-  return &ctsb, nil;
+  return &sbgp, nil;
 }
 
-func parseSyncSampleBox(data []byte, b *Box) (*SyncSampleBox, error) {
-  fb,_ := parseFullBox(data, b);
-  ssb := SyncSampleBox{Box: *fb};
-  data = data[4:];
+// parseSampleGroupEntry decodes a sample group description entry, returning
+// how many bytes it takes. Entries of unknown grouping types can only be
+// kept when their length is given.
+func parseSampleGroupEntry(groupingType string, data []byte, length int) (interface{}, int, error) {
+  switch (groupingType) {
+  case "roll", "prol":
+    if (len(data) < 2) {
+      break;
+    }
+    return RollRecoveryEntry{RollDistance: int16(binary.BigEndian.Uint16(data[0:2]))}, 2, nil;
+  case "rap ":
+    if (len(data) < 1) {
+      break;
+    }
+    return VisualRandomAccessEntry{
+      NumLeadingSamplesKnown: (data[0] & 0x80) != 0,
+      NumLeadingSamples: data[0] & 0x7f,
+    }, 1, nil;
+  case "sync":
+    if (len(data) < 1) {
+      break;
+    }
+    return SyncSampleEntry{NALUnitType: data[0] & 0x3f}, 1, nil;
+  case "tele":
+    if (len(data) < 1) {
+      break;
+    }
+    return TemporalLevelEntry{LevelIndependentlyDecodable: (data[0] & 0x80) != 0}, 1, nil;
+  case "seig":
+    if (len(data) < 20) {
+      break;
+    }
+    seig := CencSampleEncryptionInfoEntry{
+      CryptByteBlock: data[1] >> 4,
+      SkipByteBlock: data[1] & 0x0f,
+      IsProtected: data[2],
+      PerSampleIVSize: data[3],
+    };
+    copy(seig.KID[:], data[4:20]);
+    n := 20;
+    if (seig.IsProtected == 1 && seig.PerSampleIVSize == 0) {
+      if (len(data) < 21 || len(data) < 21 + int(data[20])) {
+        break;
+      }
+      seig.ConstantIV = data[21:21 + int(data[20])];
+      n += 1 + int(data[20]);
+    }
+    return seig, n, nil;
+  default:
+    if (length > 0 && length <= len(data)) {
+      return data[:length], length, nil;
+    }
+    return nil, 0, fmt.Errorf("unknown length of %q sample group entries", groupingType);
+  }
 
-  ssb.EntryCount = binary.BigEndian.Uint32(data[0:4]);
+  return nil, 0, fmt.Errorf("invalid %q sample group entry", groupingType);
+}
 
-  data = data[4:];
+func parseSampleGroupDescriptionBox(data []byte, b *Box) (*SampleGroupDescriptionBox, error) {
+  if (len(data) < 8) {
+    return nil, errors.New("invalid sgpd box");
+  }
 
-  ssb.SampleNumber = make([]uint32, ssb.EntryCount);
+  fb,_ := parseFullBox(data, b);
+  sgpd := SampleGroupDescriptionBox{Box: *fb};
 
-  for i := 0; i < int(ssb.EntryCount); i++ {
-    ssb.SampleNumber[i] = binary.BigEndian.Uint32(data[0:4]);
+  sgpd.GroupingType = string(data[4:8]);
+  data = data[8:];
+
+  if (fb.Version >= 1) {
+    if (len(data) < 4) {
+      return nil, errors.New("invalid sgpd box");
+    }
+    sgpd.DefaultLength = binary.BigEndian.Uint32(data[0:4]);
     data = data[4:];
   }
 
-  return &ssb, nil;
-}
+  if (fb.Version >= 2) {
+    if (len(data) < 4) {
+      return nil, errors.New("invalid sgpd box");
+    }
+    sgpd.DefaultSampleDescriptionIndex = binary.BigEndian.Uint32(data[0:4]);
+    data = data[4:];
+  }
 
-func parseSampleToChunkBox(data []byte, b *Box) (*SampleToChunkBox, error) {
-  fb,_ := parseFullBox(data, b);
-  stcb := SampleToChunkBox{Box: *fb};
+  if (len(data) < 4) {
+    return nil, errors.New("invalid sgpd box");
+  }
+
+  sgpd.EntryCount = binary.BigEndian.Uint32(data[0:4]);
   data = data[4:];
 
-  stcb.EntryCount = binary.BigEndian.Uint32(data[0:4]);
+  for i := 0; i < int(sgpd.EntryCount); i++ {
+    length := int(sgpd.DefaultLength);
 
-  data = data[4:];
+    if (fb.Version >= 1 && length == 0) {
+      if (len(data) < 4) {
+        return nil, errors.New("invalid sgpd box");
+      }
+      length = int(binary.BigEndian.Uint32(data[0:4]));
+      data = data[4:];
+    }
 
-  stcb.FirstChunk = make([]int32, stcb.EntryCount);
-  stcb.SamplesPerChunk = make([]int32, stcb.EntryCount);
-  stcb.SampleDescIndex = make([]int32, stcb.EntryCount);
+    if (length > len(data)) {
+      return nil, errors.New("invalid sgpd box");
+    }
 
-  for i := 0; i < int(stcb.EntryCount); i++ {
-    stcb.FirstChunk[i] = int32(binary.BigEndian.Uint32(data[0:4]));
-    stcb.SamplesPerChunk[i] = int32(binary.BigEndian.Uint32(data[4:8]));
-    stcb.SampleDescIndex[i] = int32(binary.BigEndian.Uint32(data[8:12]));
-    data = data[12:];
+    entryData := data;
+
+    if (length > 0) {
+      entryData = data[:length];
+    }
+
+    entry,n,err := parseSampleGroupEntry(sgpd.GroupingType, entryData, length);
+
+    if (err != nil) {
+      return nil, err;
+    }
+
+    // a known length wins over the decoded one
+    if (length > 0) {
+      n = length;
+    }
+
+    sgpd.Entries = append(sgpd.Entries, entry);
+    data = data[n:];
   }
 
-  return &stcb, nil;
+  return &sgpd, nil;
 }
 
-func parseSampleSizeBox(data []byte, b *Box) (*SampleSizeBox, error) {
-  fb,_ := parseFullBox(data, b);
-  ssb := SampleSizeBox{Box: *fb};
-  data = data[4:];
+func parseSubSampleInformationBox(data []byte, b *Box) (*SubSampleInformationBox, error) {
+  if (len(data) < 8) {
+    return nil, errors.New("invalid subs box");
+  }
 
-  ssb.SampleSize = binary.BigEndian.Uint32(data[0:4]);
-  ssb.SampleCount = binary.BigEndian.Uint32(data[4:8]);
+  fb,_ := parseFullBox(data, b);
+  subs := SubSampleInformationBox{Box: *fb};
 
+  subs.EntryCount = binary.BigEndian.Uint32(data[4:8]);
   data = data[8:];
 
-  if (ssb.SampleSize == 0) {
-    ssb.EntrySize = make([]uint32, ssb.SampleCount);
-    for i := 0; i < int(ssb.SampleCount); i++ {
-      ssb.EntrySize[i] = binary.BigEndian.Uint32(data[0:4]);
-      data = data[4:];
+  // subsample sizes are 32 bits wide in version 1
+  size := 2;
+
+  if (fb.Version == 1) {
+    size = 4;
+  }
+
+  for i := 0; i < int(subs.EntryCount); i++ {
+    if (len(data) < 6) {
+      return nil, errors.New("invalid subs box");
+    }
+
+    e := SubSampleInfoEntry{SampleDelta: binary.BigEndian.Uint32(data[0:4])};
+    count := int(binary.BigEndian.Uint16(data[4:6]));
+    data = data[6:];
+
+    if (len(data) < count * (size + 6)) {
+      return nil, errors.New("invalid subs box");
     }
+
+    for j := 0; j < count; j++ {
+      s := SubSampleInfo{};
+      if (size == 4) {
+        s.Size = binary.BigEndian.Uint32(data[0:4]);
+      } else {
+        s.Size = uint32(binary.BigEndian.Uint16(data[0:2]));
+      }
+      s.Priority = data[size];
+      s.Discardable = data[size + 1] != 0;
+      s.CodecSpecificParameters = binary.BigEndian.Uint32(data[size + 2:size + 6]);
+      e.Subsamples = append(e.Subsamples, s);
+      data = data[size + 6:];
+    }
+
+    subs.Entries = append(subs.Entries, e);
   }
 
-  return &ssb, nil;
+  return &subs, nil;
 }
 
-func parseChunkOffsetBox(data []byte, b *Box) (*ChunkOffsetBox, error) {
+func parseCompositionToDecodeBox(data []byte, b *Box) (*CompositionToDecodeBox, error) {
   fb,_ := parseFullBox(data, b);
-  cob := ChunkOffsetBox{Box: *fb};
+  cslg := CompositionToDecodeBox{Box: *fb};
+
   data = data[4:];
 
-  cob.EntryCount = binary.BigEndian.Uint32(data[0:4]);
+  size := 4;
 
-  data = data[4:];
+  if (fb.Version == 1) {
+    size = 8;
+  }
 
-  cob.ChunkOffset = make([]uint32, cob.EntryCount);
-  for i := 0; i < int(cob.EntryCount); i++ {
-    cob.ChunkOffset[i] = binary.BigEndian.Uint32(data[0:4]);
-    data = data[4:];
+  if (len(data) < size * 5) {
+    return nil, errors.New("invalid cslg box");
   }
 
-  return &cob, nil;
+  fields := make([]int64, 5);
+
+  for i := range fields {
+    if (size == 8) {
+      fields[i] = int64(binary.BigEndian.Uint64(data[i * 8:]));
+    } else {
+      fields[i] = int64(int32(binary.BigEndian.Uint32(data[i * 4:])));
+    }
+  }
+
+  cslg.CompositionToDTSShift = fields[0];
+  cslg.LeastDecodeToDisplayDelta = fields[1];
+  cslg.GreatestDecodeToDisplayDelta = fields[2];
+  cslg.CompositionStartTime = fields[3];
+  cslg.CompositionEndTime = fields[4];
+
+  return &cslg, nil;
 }
 
 func parseSampleTableBox(data []byte, b *Box) (*SampleTableBox, error) {
@@ -549,7 +2342,11 @@ func parseSampleTableBox(data []byte, b *Box) (*SampleTableBox, error) {
 
     switch b.Type {
     case "stsd":
-      sdb,_ := parseSampleDescBox(data[b.headerSize:b.Size], b);
+      sdb,err := parseSampleDescBox(data[b.headerSize:b.Size], b);
+      if (err != nil) {
+        fmt.Println(err);
+        break;
+      }
       stb.Stsd = *sdb;
     case "stts":
       ttsb,_ := parseTimeToSampleBox(data[b.headerSize:b.Size], b);
@@ -569,6 +2366,77 @@ func parseSampleTableBox(data []byte, b *Box) (*SampleTableBox, error) {
     case "stco":
       cob,_ := parseChunkOffsetBox(data[b.headerSize:b.Size], b);
       stb.Stco = *cob;
+    case "co64":
+      cob,err := parseChunkLargeOffsetBox(data[b.headerSize:b.Size], b);
+      if (err != nil) {
+        fmt.Println(err);
+        break;
+      }
+      stb.Co64 = *cob;
+    case "saiz":
+      saiz,err := parseSampleAuxInfoSizesBox(data[b.headerSize:b.Size], b);
+      if (err != nil) {
+        fmt.Println(err);
+        break;
+      }
+      // other auxiliary information types are not of interest
+      if (isCENCAuxInfoType(saiz.AuxInfoType)) {
+        stb.Saiz = *saiz;
+      }
+    case "saio":
+      saio,err := parseSampleAuxInfoOffsetsBox(data[b.headerSize:b.Size], b);
+      if (err != nil) {
+        fmt.Println(err);
+        break;
+      }
+      if (isCENCAuxInfoType(saio.AuxInfoType)) {
+        stb.Saio = *saio;
+      }
+    case "senc":
+      senc,err := parseSampleEncryptionBox(data[b.headerSize:b.Size], b);
+      if (err != nil) {
+        fmt.Println(err);
+        break;
+      }
+      stb.Senc = *senc;
+    case "sdtp":
+      sdtp,err := parseSampleDependencyTypeBox(data[b.headerSize:b.Size], b);
+      if (err != nil) {
+        fmt.Println(err);
+        break;
+      }
+      stb.Sdtp = *sdtp;
+    case "sbgp":
+      sbgp,err := parseSampleToGroupBox(data[b.headerSize:b.Size], b);
+      if (err != nil) {
+        fmt.Println(err);
+        break;
+      }
+      stb.Sbgp = append(stb.Sbgp, *sbgp);
+    case "sgpd":
+      sgpd,err := parseSampleGroupDescriptionBox(data[b.headerSize:b.Size], b);
+      if (err != nil) {
+        fmt.Println(err);
+        break;
+      }
+      stb.Sgpd = append(stb.Sgpd, *sgpd);
+    case "subs":
+      subs,err := parseSubSampleInformationBox(data[b.headerSize:b.Size], b);
+      if (err != nil) {
+        fmt.Println(err);
+        break;
+      }
+      // codec specific flavours may follow, the first one is kept
+      if (stb.Subs.Box.Box.Type == "") {
+        stb.Subs = *subs;
+      }
+    case "cslg":
+      cslg,err := parseCompositionToDecodeBox(data[b.headerSize:b.Size], b);
+      if (err != nil) {
+        fmt.Println(err);
+        break;
+      }
+      stb.Cslg = *cslg;
     }
 
     tsize += b.Size;
@@ -654,6 +2522,70 @@ func parseMediaBox(data []byte, b *Box) (*MediaBox, error) {
   return &mb, nil;
 }
 
+func parseEditListBox(data []byte, b *Box) (*EditListBox, error) {
+  fb,_ := parseFullBox(data, b);
+  elb := EditListBox{Box: *fb};
+  data = data[4:];
+
+  elb.EntryCount = binary.BigEndian.Uint32(data[0:4]);
+
+  data = data[4:];
+
+  elb.SegmentDuration = make([]uint64, elb.EntryCount);
+  elb.MediaTime = make([]int64, elb.EntryCount);
+  elb.MediaRateInteger = make([]int16, elb.EntryCount);
+  elb.MediaRateFraction = make([]int16, elb.EntryCount);
+
+  for i := 0; i < int(elb.EntryCount); i++ {
+    if (fb.Version == 1) {
+      elb.SegmentDuration[i] = binary.BigEndian.Uint64(data[0:8]);
+      elb.MediaTime[i] = int64(binary.BigEndian.Uint64(data[8:16]));
+      data = data[16:];
+    } else {
+      elb.SegmentDuration[i] = uint64(binary.BigEndian.Uint32(data[0:4]));
+      elb.MediaTime[i] = int64(int32(binary.BigEndian.Uint32(data[4:8])));
+      data = data[8:];
+    }
+    elb.MediaRateInteger[i] = int16(binary.BigEndian.Uint16(data[0:2]));
+    elb.MediaRateFraction[i] = int16(binary.BigEndian.Uint16(data[2:4]));
+    data = data[4:];
+  }
+
+  return &elb, nil;
+}
+
+func parseEditBox(data []byte, b *Box) (*EditBox, error) {
+  eb := EditBox{Box: *b};
+
+  tsize := b.headerSize;
+
+  for {
+    b,err := parseBox(data);
+
+    if (err != nil) {
+      return nil, err;
+    }
+
+    fmt.Println("      -", b.Type);
+
+    switch b.Type {
+    case "elst":
+      elb,_ := parseEditListBox(data[b.headerSize:b.Size], b);
+      eb.Elst = *elb;
+    }
+
+    tsize += b.Size;
+
+    if (tsize == eb.Box.Size) {
+      break;
+    }
+
+    data = data[b.Size:];
+  }
+
+  return &eb, nil;
+}
+
 func parseTrackBox(data []byte, b *Box) (*TrackBox, error) {
   tb := TrackBox{Box: *b};
 
@@ -672,6 +2604,9 @@ func parseTrackBox(data []byte, b *Box) (*TrackBox, error) {
     case "tkhd":
       thb,_ := parseTrackHeaderBox(data[b.headerSize:b.Size], b);
       tb.Tkhd = *thb;
+    case "edts":
+      eb,_ := parseEditBox(data[b.headerSize:b.Size], b);
+      tb.Edts = *eb;
     case "mdia":
       mb,_ := parseMediaBox(data[b.headerSize:b.Size], b);
       tb.Mdia = *mb;
@@ -689,6 +2624,87 @@ func parseTrackBox(data []byte, b *Box) (*TrackBox, error) {
   return &tb, nil;
 }
 
+func parseEventMessageBox(data []byte, b *Box) (*EventMessageBox, error) {
+  if (len(data) < 4) {
+    return nil, errors.New("invalid emsg box");
+  }
+
+  fb,_ := parseFullBox(data, b);
+  emsg := EventMessageBox{Box: *fb};
+
+  data = data[4:];
+
+  switch (fb.Version) {
+  case 0:
+    emsg.SchemeIdURI,data = readCString(data);
+    emsg.Value,data = readCString(data);
+    if (len(data) < 16) {
+      return nil, errors.New("invalid emsg box");
+    }
+    emsg.Timescale = binary.BigEndian.Uint32(data[0:4]);
+    emsg.PresentationTimeDelta = binary.BigEndian.Uint32(data[4:8]);
+    emsg.EventDuration = binary.BigEndian.Uint32(data[8:12]);
+    emsg.Id = binary.BigEndian.Uint32(data[12:16]);
+    data = data[16:];
+  case 1:
+    if (len(data) < 20) {
+      return nil, errors.New("invalid emsg box");
+    }
+    emsg.Timescale = binary.BigEndian.Uint32(data[0:4]);
+    emsg.PresentationTime = binary.BigEndian.Uint64(data[4:12]);
+    emsg.EventDuration = binary.BigEndian.Uint32(data[12:16]);
+    emsg.Id = binary.BigEndian.Uint32(data[16:20]);
+    emsg.SchemeIdURI,data = readCString(data[20:]);
+    emsg.Value,data = readCString(data);
+  default:
+    return nil, errors.New("unsupported emsg version");
+  }
+
+  emsg.MessageData = data;
+
+  return &emsg, nil;
+}
+
+func parseProtectionSystemSpecificHeaderBox(data []byte, b *Box) (*ProtectionSystemSpecificHeaderBox, error) {
+  if (len(data) < 24) {
+    return nil, errors.New("invalid pssh box");
+  }
+
+  fb,_ := parseFullBox(data, b);
+  pssh := ProtectionSystemSpecificHeaderBox{Box: *fb};
+
+  copy(pssh.SystemID[:], data[4:20]);
+
+  data = data[20:];
+
+  if (fb.Version > 0) {
+    n := binary.BigEndian.Uint32(data[0:4]);
+    data = data[4:];
+    if (uint64(len(data)) < uint64(n) * 16 + 4) {
+      return nil, errors.New("invalid pssh box");
+    }
+    pssh.KIDs = make([]KID, n);
+    for i := range pssh.KIDs {
+      copy(pssh.KIDs[i][:], data[i * 16:]);
+    }
+    data = data[n * 16:];
+  }
+
+  if (len(data) < 4) {
+    return nil, errors.New("invalid pssh box");
+  }
+
+  size := binary.BigEndian.Uint32(data[0:4]);
+
+  if (uint64(len(data) - 4) < uint64(size)) {
+    return nil, errors.New("invalid pssh box");
+  }
+
+  pssh.Data = data[4:4 + size];
+
+  return &pssh, nil;
+}
+
 func parseMovieBox(data []byte, b *Box) (*MovieBox, error) {
   mb := MovieBox{Box: *b};
 
@@ -710,6 +2726,13 @@ func parseMovieBox(data []byte, b *Box) (*MovieBox, error) {
     case "trak":
       tb,_ := parseTrackBox(data[b.headerSize:b.Size], b);
       mb.Tracks = append(mb.Tracks, *tb);
+    case "pssh":
+      pssh,err := parseProtectionSystemSpecificHeaderBox(data[b.headerSize:b.Size], b);
+      if (err != nil) {
+        fmt.Println(err);
+        break;
+      }
+      mb.Pssh = append(mb.Pssh, *pssh);
     }
 
     tsize += b.Size;
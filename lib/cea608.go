@@ -0,0 +1,281 @@
+package mp4
+
+import (
+  "time"
+  "strings"
+)
+
+const (
+  cea608PopOn = iota
+  cea608RollUp
+  cea608PaintOn
+)
+
+const (
+  cea608Rows = 15
+  cea608Cols = 32
+)
+
+// PAC row numbers, indexed by the low bits of the first byte and bit 5 of
+// the second one
+var cea608PACRows = []int{11, 11, 1, 2, 3, 4, 12, 13, 14, 15, 5, 6, 7, 8, 9, 10};
+
+var cea608SpecialChars = []rune("®°½¿™¢£♪à èâêîôû");
+
+var cea608ExtendedChars = []rune(
+  "ÁÉÓÚÜü‘¡*’—©℠•“”ÀÂÇÈÊËëÎÏïÔÙùÛ«»" +
+  "ÃãÍÌìÒòÕõ{}\\^_|~ÄäÖöß¥¤¦ÅåØø┌┐└┘");
+
+// basic characters which differ from ASCII
+var cea608BasicChars = map[byte]rune{
+  0x2a: 'á', 0x5c: 'é', 0x5e: 'í', 0x5f: 'ó', 0x60: 'ú',
+  0x7b: 'ç', 0x7c: '÷', 0x7d: 'Ñ', 0x7e: 'ñ', 0x7f: '█',
+};
+
+type cea608Memory [cea608Rows][]rune
+
+func (m *cea608Memory) text() string {
+  var lines []string;
+
+  for _,row := range m {
+    line := strings.TrimSpace(string(row));
+    if (line != "") {
+      lines = append(lines, line);
+    }
+  }
+
+  return strings.Join(lines, "\n");
+}
+
+// cea608Decoder turns the CC1 channel of c608 samples into cues. The
+// displayed memory is compared after each sample, any change closes the
+// cue on screen and opens a new one.
+type cea608Decoder struct {
+  mode int
+  rollUpRows int
+  displayed cea608Memory
+  nonDisplayed cea608Memory
+  row int
+  col int
+  channel int
+  lastCtrl [2]byte
+  shown string
+  shownAt time.Duration
+}
+
+func newCEA608Decoder() *cea608Decoder {
+  return &cea608Decoder{row: cea608Rows - 1, channel: 1};
+}
+
+func (d *cea608Decoder) memory() *cea608Memory {
+  if (d.mode == cea608PopOn) {
+    return &d.nonDisplayed;
+  }
+  return &d.displayed;
+}
+
+func (d *cea608Decoder) putChar(r rune) {
+  if (d.col >= cea608Cols) {
+    return;
+  }
+
+  line := d.memory()[d.row];
+
+  for len(line) < d.col {
+    line = append(line, ' ');
+  }
+
+  if (d.col < len(line)) {
+    line[d.col] = r;
+  } else {
+    line = append(line, r);
+  }
+
+  d.memory()[d.row] = line;
+  d.col++;
+}
+
+func (d *cea608Decoder) backspace() {
+  if (d.col == 0) {
+    return;
+  }
+
+  d.col--;
+
+  line := d.memory()[d.row];
+
+  if (d.col < len(line)) {
+    d.memory()[d.row] = line[:d.col];
+  }
+}
+
+func (d *cea608Decoder) carriageReturn() {
+  if (d.mode != cea608RollUp) {
+    return;
+  }
+
+  top := d.row - d.rollUpRows + 1;
+
+  if (top < 0) {
+    top = 0;
+  }
+
+  for r := 0; r < d.row; r++ {
+    if (r < top) {
+      d.displayed[r] = nil;
+    } else {
+      d.displayed[r] = d.displayed[r + 1];
+    }
+  }
+
+  d.displayed[d.row] = nil;
+  d.col = 0;
+}
+
+func (d *cea608Decoder) control(b1 byte, b2 byte) {
+  // the channel bit selects between CC1 and CC2
+  if ((b1 & 0x08) != 0) {
+    d.channel = 2;
+    return;
+  }
+
+  d.channel = 1;
+
+  switch {
+  case b1 == 0x14 && b2 >= 0x20 && b2 <= 0x2f:
+    switch (b2) {
+    case 0x20:
+      d.mode = cea608PopOn;
+    case 0x21:
+      d.backspace();
+    case 0x24:
+      line := d.memory()[d.row];
+      if (d.col < len(line)) {
+        d.memory()[d.row] = line[:d.col];
+      }
+    case 0x25, 0x26, 0x27:
+      if (d.mode != cea608RollUp) {
+        d.displayed = cea608Memory{};
+        d.nonDisplayed = cea608Memory{};
+      }
+      d.mode = cea608RollUp;
+      d.rollUpRows = int(b2 - 0x23);
+    case 0x29:
+      d.mode = cea608PaintOn;
+    case 0x2c:
+      d.displayed = cea608Memory{};
+    case 0x2d:
+      d.carriageReturn();
+    case 0x2e:
+      d.nonDisplayed = cea608Memory{};
+    case 0x2f:
+      d.displayed,d.nonDisplayed = d.nonDisplayed,d.displayed;
+      d.mode = cea608PopOn;
+    }
+  case b1 == 0x11 && b2 >= 0x30 && b2 <= 0x3f:
+    d.putChar(cea608SpecialChars[b2 - 0x30]);
+  case (b1 == 0x12 || b1 == 0x13) && b2 >= 0x20 && b2 <= 0x3f:
+    // extended characters replace the basic fallback sent before them
+    d.backspace();
+    d.putChar(cea608ExtendedChars[int(b1 - 0x12) * 32 + int(b2 - 0x20)]);
+  case b1 == 0x11 && b2 >= 0x20 && b2 <= 0x2f:
+    // mid-row style change, displayed as a space
+    d.putChar(' ');
+  case b1 == 0x17 && b2 >= 0x21 && b2 <= 0x23:
+    d.col += int(b2 - 0x20);
+    if (d.col > cea608Cols - 1) {
+      d.col = cea608Cols - 1;
+    }
+  case b2 >= 0x40:
+    row := cea608PACRows[((b1 & 0x07) << 1) | ((b2 >> 5) & 0x01)] - 1;
+    if (d.mode == cea608RollUp && row != d.row) {
+      // move the roll-up window to the new base row
+      var moved cea608Memory;
+      for r := 0; r < d.rollUpRows; r++ {
+        if (d.row - r >= 0 && row - r >= 0) {
+          moved[row - r] = d.displayed[d.row - r];
+        }
+      }
+      d.displayed = moved;
+    }
+    d.row = row;
+    d.col = 0;
+    if ((b2 & 0x10) != 0) {
+      d.col = int((b2 & 0x0e) >> 1) * 4;
+    }
+  }
+}
+
+func (d *cea608Decoder) decodePair(b1 byte, b2 byte) {
+  b1 &= 0x7f;
+  b2 &= 0x7f;
+
+  if (b1 == 0 && b2 == 0) {
+    return;
+  }
+
+  if (b1 >= 0x10 && b1 <= 0x1f) {
+    // control codes are sent twice for robustness
+    if (d.lastCtrl == [2]byte{b1, b2}) {
+      d.lastCtrl = [2]byte{};
+      return;
+    }
+    d.lastCtrl = [2]byte{b1, b2};
+    d.control(b1, b2);
+    return;
+  }
+
+  d.lastCtrl = [2]byte{};
+
+  if (d.channel != 1) {
+    return;
+  }
+
+  for _,c := range []byte{b1, b2} {
+    if (c < 0x20) {
+      continue;
+    }
+    if r,ok := cea608BasicChars[c]; ok {
+      d.putChar(r);
+    } else {
+      d.putChar(rune(c));
+    }
+  }
+}
+
+// decode processes the field 1 byte pairs of a c608 sample presented at
+// start and appends the cues it completes.
+func (d *cea608Decoder) decode(data []byte, start time.Duration, cues []Cue) []Cue {
+  iterBoxes(data, func(b *Box, data []byte) {
+    if (b.Type != "cdat") {
+      return;
+    }
+    for i := 0; i + 1 < len(data); i += 2 {
+      d.decodePair(data[i], data[i + 1]);
+    }
+  });
+
+  text := d.displayed.text();
+
+  if (text == d.shown) {
+    return cues;
+  }
+
+  cues = d.flush(start, cues);
+
+  d.shown = text;
+  d.shownAt = start;
+
+  return cues;
+}
+
+// flush closes the cue on screen at end.
+func (d *cea608Decoder) flush(end time.Duration, cues []Cue) []Cue {
+  if (d.shown != "" && end > d.shownAt) {
+    cues = append(cues, Cue{Start: d.shownAt, End: end, Text: d.shown});
+  }
+
+  d.shown = "";
+
+  return cues;
+}
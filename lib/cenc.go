@@ -0,0 +1,413 @@
+package mp4
+
+import (
+  "fmt"
+  "errors"
+  "encoding/hex"
+  "encoding/binary"
+)
+
+// protection schemes (ISO/IEC 23001-7)
+const (
+  CENC_SCHEME_CENC = "cenc"
+  CENC_SCHEME_CBC1 = "cbc1"
+  CENC_SCHEME_CENS = "cens"
+  CENC_SCHEME_CBCS = "cbcs"
+)
+
+// isCENCAuxInfoType tells whether saiz/saio boxes of the given aux_info_type
+// hold sample encryption info, an empty type defaults to the scheme type.
+func isCENCAuxInfoType(typ string) bool {
+  switch (typ) {
+  case "", CENC_SCHEME_CENC, CENC_SCHEME_CBC1, CENC_SCHEME_CENS, CENC_SCHEME_CBCS:
+    return true;
+  }
+  return false;
+}
+
+// KID is a 16 byte key identifier.
+type KID [16]byte
+
+// SystemID is the 16 byte identifier of a DRM system found in pssh boxes.
+type SystemID [16]byte
+
+func formatUUID(id [16]byte) string {
+  h := hex.EncodeToString(id[:]);
+  return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32];
+}
+
+func parseUUID(s string) ([16]byte, error) {
+  var id [16]byte;
+
+  b := make([]byte, 0, 32);
+
+  for i := 0; i < len(s); i++ {
+    if (s[i] != '-') {
+      b = append(b, s[i]);
+    }
+  }
+
+  if (len(b) != 32) {
+    return id, fmt.Errorf("invalid uuid %q", s);
+  }
+
+  _,err := hex.Decode(id[:], b);
+
+  return id, err;
+}
+
+func (kid KID) String() string {
+  return formatUUID(kid);
+}
+
+func (kid KID) MarshalText() ([]byte, error) {
+  return []byte(kid.String()), nil;
+}
+
+// ParseKID parses a key ID either in UUID form or as 32 hex digits.
+func ParseKID(s string) (KID, error) {
+  id,err := parseUUID(s);
+  return KID(id), err;
+}
+
+func (id SystemID) String() string {
+  return formatUUID(id);
+}
+
+func (id SystemID) MarshalText() ([]byte, error) {
+  return []byte(id.String()), nil;
+}
+
+func mustSystemID(s string) SystemID {
+  id,err := parseUUID(s);
+  if (err != nil) {
+    panic(err);
+  }
+  return SystemID(id);
+}
+
+var (
+  SYSTEM_ID_WIDEVINE = mustSystemID("edef8ba9-79d6-4ace-a3c8-27dcd51d21ed")
+  SYSTEM_ID_PLAYREADY = mustSystemID("9a04f079-9840-4286-ab92-e65be0885f95")
+  SYSTEM_ID_FAIRPLAY = mustSystemID("94ce86fb-07ff-4f43-adb8-93d2fa968ca2")
+  SYSTEM_ID_CLEARKEY = mustSystemID("1077efec-c0b2-4d02-ace3-3c1e52e2fb4b")
+)
+
+// DRMSystems maps well known system IDs to their name.
+var DRMSystems = map[SystemID]string{
+  SYSTEM_ID_WIDEVINE: "Widevine",
+  SYSTEM_ID_PLAYREADY: "PlayReady",
+  SYSTEM_ID_FAIRPLAY: "FairPlay",
+  SYSTEM_ID_CLEARKEY: "ClearKey",
+};
+
+// Name returns the name of a registered DRM system, or an empty string.
+func (id SystemID) Name() string {
+  return DRMSystems[id];
+}
+
+// Entries decodes the per sample IVs and subsample maps. The IV size comes
+// from the track's tenc, zero meaning a constant IV is used.
+func (senc *SampleEncryptionBox) Entries(ivSize uint8) ([]SampleEncryptionEntry, error) {
+  return senc.entries(func(i int) uint8 {
+    return ivSize;
+  });
+}
+
+// entries decodes senc with an IV size that can change from sample to
+// sample, as signalled by seig sample groups.
+func (senc *SampleEncryptionBox) entries(ivSize func(i int) uint8) ([]SampleEncryptionEntry, error) {
+  data := senc.data;
+  subsamples := (senc.Box.Flags[2] & 0x02) != 0;
+
+  entries := make([]SampleEncryptionEntry, 0, senc.SampleCount);
+
+  for i := 0; i < int(senc.SampleCount); i++ {
+    e,n,err := parseSampleEncryptionEntry(data, ivSize(i), subsamples);
+    if (err != nil) {
+      return nil, err;
+    }
+    entries = append(entries, *e);
+    data = data[n:];
+  }
+
+  return entries, nil;
+}
+
+func parseSampleEncryptionEntry(data []byte, ivSize uint8, subsamples bool) (*SampleEncryptionEntry, int, error) {
+  e := SampleEncryptionEntry{};
+
+  if (len(data) < int(ivSize)) {
+    return nil, 0, errors.New("invalid sample encryption entry");
+  }
+
+  e.IV = data[:ivSize];
+  n := int(ivSize);
+
+  if (!subsamples) {
+    return &e, n, nil;
+  }
+
+  if (len(data) < n + 2) {
+    return nil, 0, errors.New("invalid sample encryption entry");
+  }
+
+  count := int(binary.BigEndian.Uint16(data[n:n + 2]));
+  n += 2;
+
+  if (len(data) < n + count * 6) {
+    return nil, 0, errors.New("invalid sample encryption entry");
+  }
+
+  for j := 0; j < count; j++ {
+    e.Subsamples = append(e.Subsamples, SubsampleEntry{
+      BytesOfClearData: binary.BigEndian.Uint16(data[n:n + 2]),
+      BytesOfProtectedData: binary.BigEndian.Uint32(data[n + 2:n + 6]),
+    });
+    n += 6;
+  }
+
+  return &e, n, nil;
+}
+
+func protectionInfo(entry *SampleEntry) *ProtectionSchemeInfoBox {
+  ext := findExtension(entry, func(ext interface{}) bool {
+    _,ok := ext.(ProtectionSchemeInfoBox);
+    return ok;
+  });
+
+  if (ext == nil) {
+    return nil;
+  }
+
+  sinf := ext.(ProtectionSchemeInfoBox);
+
+  return &sinf;
+}
+
+// ProtectionInfo returns the sinf box of an encrypted (encv/enca) track.
+func (t *Track) ProtectionInfo() (*ProtectionSchemeInfoBox, error) {
+  entry,err := t.SampleEntry();
+
+  if (err != nil) {
+    return nil, err;
+  }
+
+  sinf := protectionInfo(entry);
+
+  if (sinf == nil) {
+    return nil, errors.New("track is not encrypted");
+  }
+
+  return sinf, nil;
+}
+
+// SampleEncryptionInfo returns the protection parameters of every sample,
+// taken from the seig sample group the sample belongs to or else from the
+// tenc defaults.
+func (t *Track) SampleEncryptionInfo() ([]*CencSampleEncryptionInfoEntry, error) {
+  sinf,err := t.ProtectionInfo();
+
+  if (err != nil) {
+    return nil, err;
+  }
+
+  tenc := &sinf.Tenc;
+  stbl := &t.Box.Mdia.Minf.Stbl;
+
+  def := &CencSampleEncryptionInfoEntry{
+    CryptByteBlock: tenc.DefaultCryptByteBlock,
+    SkipByteBlock: tenc.DefaultSkipByteBlock,
+    IsProtected: tenc.DefaultIsProtected,
+    PerSampleIVSize: tenc.DefaultPerSampleIVSize,
+    KID: tenc.DefaultKID,
+    ConstantIV: tenc.DefaultConstantIV,
+  };
+
+  info := make([]*CencSampleEncryptionInfoEntry, len(t.samples));
+
+  for i := range info {
+    info[i] = def;
+  }
+
+  for k := range stbl.Sgpd {
+    sgpd := &stbl.Sgpd[k];
+
+    if (sgpd.GroupingType != "seig") {
+      continue;
+    }
+
+    for i,gdi := range sampleGroupIndex(stbl, sgpd, len(info)) {
+      if (gdi == 0) {
+        continue;
+      }
+      if (int(gdi) > len(sgpd.Entries)) {
+        return nil, fmt.Errorf("sample %d: invalid seig group description index", i + 1);
+      }
+      seig,ok := sgpd.Entries[gdi - 1].(CencSampleEncryptionInfoEntry);
+      if (!ok) {
+        return nil, errors.New("invalid seig sample group entry");
+      }
+      info[i] = &seig;
+    }
+  }
+
+  return info, nil;
+}
+
+// SampleEncryption returns the IV and subsample map of every sample, taken
+// from senc when present or else read from the auxiliary information
+// pointed at by saiz/saio. Samples using a constant IV get it from their
+// seig group or tenc. Entries of unprotected samples have no IV.
+//
+// Only the boxes found in stbl are read. Fragmented files, which carry
+// senc/saiz/saio in each moof/traf, are not supported since movie
+// fragments are not parsed.
+func (t *Track) SampleEncryption() ([]SampleEncryptionEntry, error) {
+  info,err := t.SampleEncryptionInfo();
+
+  if (err != nil) {
+    return nil, err;
+  }
+
+  stbl := &t.Box.Mdia.Minf.Stbl;
+
+  protected := false;
+  perSampleIV := false;
+
+  for _,p := range info {
+    if (p.IsProtected != 0) {
+      protected = true;
+      perSampleIV = perSampleIV || p.PerSampleIVSize > 0;
+    }
+  }
+
+  ivSize := func(i int) uint8 {
+    if (i >= len(info)) {
+      return 0;
+    }
+    return info[i].PerSampleIVSize;
+  };
+
+  var entries []SampleEncryptionEntry;
+
+  switch {
+  case !protected:
+    // samples are in the clear, entries without an IV mark them as such
+    return make([]SampleEncryptionEntry, len(t.samples)), nil;
+  case stbl.Senc.Box.Box.Type != "":
+    entries,err = stbl.Senc.entries(ivSize);
+    if (err != nil) {
+      return nil, err;
+    }
+  case stbl.Saiz.Box.Box.Type != "" && stbl.Saio.Box.Box.Type != "":
+    entries,err = t.readSampleAuxInfo(ivSize);
+    if (err != nil) {
+      return nil, err;
+    }
+  case perSampleIV:
+    return nil, errors.New("per sample IVs signalled but no sample auxiliary information found");
+  default:
+    entries = make([]SampleEncryptionEntry, len(t.samples));
+  }
+
+  if (len(entries) != len(t.samples)) {
+    return nil, errors.New("sample encryption count mismatch");
+  }
+
+  for i,p := range info {
+    switch {
+    case p.IsProtected == 0:
+      entries[i].IV = nil;
+    case p.PerSampleIVSize == 0:
+      if (len(p.ConstantIV) == 0) {
+        return nil, fmt.Errorf("sample %d has neither a per sample nor a constant IV", i + 1);
+      }
+      entries[i].IV = p.ConstantIV;
+    }
+  }
+
+  return entries, nil;
+}
+
+func (t *Track) readSampleAuxInfo(ivSize func(i int) uint8) ([]SampleEncryptionEntry, error) {
+  stbl := &t.Box.Mdia.Minf.Stbl;
+  saiz := &stbl.Saiz;
+  saio := &stbl.Saio;
+
+  n := int(saiz.SampleCount);
+
+  sizes := make([]int, n);
+
+  for i := range sizes {
+    if (saiz.DefaultSampleInfoSize != 0) {
+      sizes[i] = int(saiz.DefaultSampleInfoSize);
+    } else {
+      sizes[i] = int(saiz.SampleInfoSize[i]);
+    }
+  }
+
+  // a single offset covers the info of all samples, otherwise there is one
+  // offset per chunk
+  var runs [][2]int;
+
+  if (saio.EntryCount == 1) {
+    runs = append(runs, [2]int{0, n});
+  } else {
+    idx := 0;
+    for _,c := range chunkSampleCounts(stbl) {
+      runs = append(runs, [2]int{idx, c});
+      idx += c;
+    }
+  }
+
+  if (len(runs) != int(saio.EntryCount)) {
+    return nil, errors.New("saio entry count mismatch");
+  }
+
+  entries := make([]SampleEncryptionEntry, 0, n);
+
+  for r,run := range runs {
+    total := 0;
+    for i := run[0]; i < run[0] + run[1] && i < n; i++ {
+      total += sizes[i];
+    }
+
+    buf := make([]byte, total);
+
+    _,err := t.r.ReadAt(buf, int64(saio.Offsets[r]));
+
+    if (err != nil) {
+      return nil, err;
+    }
+
+    for i := run[0]; i < run[0] + run[1] && i < n; i++ {
+      e,_,err := parseSampleEncryptionEntry(buf[:sizes[i]], ivSize(i), sizes[i] > int(ivSize(i)));
+      if (err != nil) {
+        return nil, err;
+      }
+      entries = append(entries, *e);
+      buf = buf[sizes[i]:];
+    }
+  }
+
+  return entries, nil;
+}
+
+// chunkSampleCounts expands stsc into the number of samples of each chunk.
+func chunkSampleCounts(stbl *SampleTableBox) []int {
+  nchunks := len(chunkOffsets(stbl));
+  counts := make([]int, 0, nchunks);
+
+  for i := 0; i < int(stbl.Stsc.EntryCount); i++ {
+    last := nchunks;
+    if (i + 1 < int(stbl.Stsc.EntryCount)) {
+      last = int(stbl.Stsc.FirstChunk[i + 1]) - 1;
+    }
+    for c := int(stbl.Stsc.FirstChunk[i]) - 1; c < last; c++ {
+      counts = append(counts, int(stbl.Stsc.SamplesPerChunk[i]));
+    }
+  }
+
+  return counts;
+}
@@ -0,0 +1,291 @@
+package mp4
+
+import (
+  "fmt"
+  "strings"
+)
+
+func findExtension(entry *SampleEntry, match func(ext interface{}) bool) interface{} {
+  for _,ext := range entry.Extensions {
+    if (match(ext)) {
+      return ext;
+    }
+  }
+  return nil;
+}
+
+func avcCodecString(entry *SampleEntry) string {
+  ext := findExtension(entry, func(ext interface{}) bool {
+    _,ok := ext.(AVCcBox);
+    return ok;
+  });
+
+  if (ext == nil) {
+    return entry.Box.Type;
+  }
+
+  avcc := ext.(AVCcBox);
+
+  return fmt.Sprintf("%s.%02x%02x%02x", entry.Box.Type, avcc.Profile, avcc.Compatibility, avcc.Level);
+}
+
+func hevcCodecString(entry *SampleEntry) string {
+  ext := findExtension(entry, func(ext interface{}) bool {
+    _,ok := ext.(HVCcBox);
+    return ok;
+  });
+
+  if (ext == nil) {
+    return entry.Box.Type;
+  }
+
+  hvcc := ext.(HVCcBox);
+
+  space := []string{"", "A", "B", "C"}[hvcc.ProfileSpace];
+
+  // compatibility flags are written in reverse bit order
+  var compat uint32;
+
+  for i := 0; i < 32; i++ {
+    compat |= ((hvcc.ProfileCompatibility >> uint(i)) & 0x01) << uint(31 - i);
+  }
+
+  tier := "L";
+
+  if (hvcc.TierFlag == 1) {
+    tier = "H";
+  }
+
+  res := fmt.Sprintf("%s.%s%d.%X.%s%d", entry.Box.Type, space, hvcc.ProfileIdc, compat, tier, hvcc.Level);
+
+  // trailing zero constraint bytes are omitted
+  n := len(hvcc.ConstraintIndicator);
+
+  for n > 0 && hvcc.ConstraintIndicator[n - 1] == 0 {
+    n--;
+  }
+
+  for i := 0; i < n; i++ {
+    res += fmt.Sprintf(".%X", hvcc.ConstraintIndicator[i]);
+  }
+
+  return res;
+}
+
+func av1CodecString(entry *SampleEntry) string {
+  ext := findExtension(entry, func(ext interface{}) bool {
+    _,ok := ext.(AV1cBox);
+    return ok;
+  });
+
+  if (ext == nil) {
+    return entry.Box.Type;
+  }
+
+  av1c := ext.(AV1cBox);
+
+  tier := "M";
+
+  if (av1c.SeqTier == 1) {
+    tier = "H";
+  }
+
+  res := fmt.Sprintf("%s.%d.%02d%s.%02d", entry.Box.Type, av1c.SeqProfile, av1c.SeqLevelIdx, tier, av1c.BitDepth);
+
+  sh := av1c.SequenceHeader;
+
+  if (sh == nil) {
+    return res;
+  }
+
+  mono := 0;
+
+  if (sh.MonoChrome) {
+    mono = 1;
+  }
+
+  chroma := fmt.Sprintf("%d%d%d", sh.SubsamplingX, sh.SubsamplingY, sh.ChromaSamplePosition);
+
+  // the optional fields are omitted altogether when they hold the defaults
+  cp,tc,mc := sh.ColorPrimaries, sh.TransferCharacteristics, sh.MatrixCoefficients;
+
+  if (!sh.ColorDescriptionPresent) {
+    cp,tc,mc = 1, 1, 1;
+  }
+
+  if (mono == 0 && chroma == "110" && cp == 1 && tc == 1 && mc == 1 && !sh.FullRange) {
+    return res;
+  }
+
+  fullRange := 0;
+
+  if (sh.FullRange) {
+    fullRange = 1;
+  }
+
+  return res + fmt.Sprintf(".%d.%s.%02d.%02d.%02d.%d", mono, chroma, cp, tc, mc, fullRange);
+}
+
+func vpxCodecString(entry *SampleEntry) string {
+  ext := findExtension(entry, func(ext interface{}) bool {
+    _,ok := ext.(VPcCBox);
+    return ok;
+  });
+
+  if (ext == nil) {
+    return entry.Box.Type;
+  }
+
+  vpcc := ext.(VPcCBox);
+
+  res := fmt.Sprintf("%s.%02d.%02d.%02d", entry.Box.Type, vpcc.Profile, vpcc.Level, vpcc.BitDepth);
+
+  if (vpcc.Box.Version == 0) {
+    return res;
+  }
+
+  // as for AV1 the optional fields are left out when they hold the defaults
+  if (vpcc.ChromaSubsampling == 1 && vpcc.ColourPrimaries == 1 && vpcc.TransferCharacteristics == 1 &&
+    vpcc.MatrixCoefficients == 1 && !vpcc.FullRange) {
+    return res;
+  }
+
+  fullRange := 0;
+
+  if (vpcc.FullRange) {
+    fullRange = 1;
+  }
+
+  return res + fmt.Sprintf(".%02d.%02d.%02d.%02d.%02d", vpcc.ChromaSubsampling, vpcc.ColourPrimaries,
+    vpcc.TransferCharacteristics, vpcc.MatrixCoefficients, fullRange);
+}
+
+func ac4CodecString(entry *SampleEntry) string {
+  ext := findExtension(entry, func(ext interface{}) bool {
+    _,ok := ext.(AC4SpecificBox);
+    return ok;
+  });
+
+  if (ext == nil) {
+    return entry.Box.Type;
+  }
+
+  dac4 := ext.(AC4SpecificBox);
+
+  return fmt.Sprintf("%s.%02d.%02d.%02d", entry.Box.Type, dac4.BitstreamVersion, dac4.PresentationVersion, dac4.MdCompat);
+}
+
+func dolbyVisionCodecString(entry *SampleEntry) string {
+  ext := findExtension(entry, func(ext interface{}) bool {
+    _,ok := ext.(DolbyVisionConfigBox);
+    return ok;
+  });
+
+  if (ext == nil) {
+    return entry.Box.Type;
+  }
+
+  dv := ext.(DolbyVisionConfigBox);
+
+  return fmt.Sprintf("%s.%02d.%02d", entry.Box.Type, dv.Profile, dv.Level);
+}
+
+// audioObjectType reads the (possibly escaped) object type at the start of
+// an AudioSpecificConfig.
+func audioObjectType(config []byte) int {
+  if (len(config) == 0) {
+    return 0;
+  }
+
+  aot := int(config[0] >> 3);
+
+  if (aot == 31 && len(config) > 1) {
+    aot = 32 + int((config[0] & 0x07) << 3 | config[1] >> 5);
+  }
+
+  return aot;
+}
+
+func mp4aCodecString(entry *SampleEntry) string {
+  ext := findExtension(entry, func(ext interface{}) bool {
+    _,ok := ext.(ElementaryStreamDescBox);
+    return ok;
+  });
+
+  if (ext == nil) {
+    return entry.Box.Type;
+  }
+
+  esds := ext.(ElementaryStreamDescBox);
+
+  if (esds.Esd.ObjectType == 0) {
+    return entry.Box.Type;
+  }
+
+  // only MPEG-4 audio carries an object type in the codec string
+  if (esds.Esd.ObjectType != 0x40 || len(esds.Esd.Config) == 0) {
+    return fmt.Sprintf("%s.%02x", entry.Box.Type, esds.Esd.ObjectType);
+  }
+
+  return fmt.Sprintf("%s.%02x.%d", entry.Box.Type, esds.Esd.ObjectType, audioObjectType(esds.Esd.Config));
+}
+
+func mp4vCodecString(entry *SampleEntry) string {
+  ext := findExtension(entry, func(ext interface{}) bool {
+    _,ok := ext.(ElementaryStreamDescBox);
+    return ok;
+  });
+
+  if (ext == nil) {
+    return entry.Box.Type;
+  }
+
+  esds := ext.(ElementaryStreamDescBox);
+
+  if (esds.Esd.ObjectType == 0) {
+    return entry.Box.Type;
+  }
+
+  // MPEG-4 Visual appends the decimal profile_and_level_indication
+  if (esds.Esd.ObjectType == 0x20 && esds.Esd.VisualConfig != nil && esds.Esd.VisualConfig.ProfileLevel != 0) {
+    return fmt.Sprintf("%s.%02x.%d", entry.Box.Type, esds.Esd.ObjectType, esds.Esd.VisualConfig.ProfileLevel);
+  }
+
+  return fmt.Sprintf("%s.%02x", entry.Box.Type, esds.Esd.ObjectType);
+}
+
+// CodecString returns the RFC 6381 codecs parameter for a sample entry, as
+// used by HLS CODECS attributes and MediaSource.isTypeSupported. Entries
+// missing their configuration box fall back to the bare sample entry type.
+func CodecString(entry SampleEntry) string {
+  switch entry.Box.Type {
+  case "encv", "enca":
+    // encrypted entries describe the codec of the original format
+    sinf := protectionInfo(&entry);
+    if (sinf != nil && sinf.Frma.DataFormat != "") {
+      entry.Box.Type = sinf.Frma.DataFormat;
+      return CodecString(entry);
+    }
+  case "avc1", "avc2", "avc3", "avc4":
+    return avcCodecString(&entry);
+  case "hvc1", "hev1":
+    return hevcCodecString(&entry);
+  case "av01":
+    return av1CodecString(&entry);
+  case "vp08", "vp09":
+    return vpxCodecString(&entry);
+  case "dvh1", "dvhe", "dva1", "dvav", "dav1":
+    return dolbyVisionCodecString(&entry);
+  case "mp4a":
+    return mp4aCodecString(&entry);
+  case "mp4v":
+    return mp4vCodecString(&entry);
+  case "ac-3", "ec-3":
+    return entry.Box.Type;
+  case "ac-4":
+    return ac4CodecString(&entry);
+  case "Opus", "fLaC":
+    return strings.ToLower(entry.Box.Type);
+  }
+  return entry.Box.Type;
+}
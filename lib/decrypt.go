@@ -0,0 +1,215 @@
+package mp4
+
+import (
+  "fmt"
+  "errors"
+  "crypto/aes"
+  "crypto/cipher"
+)
+
+type sampleDecrypter struct {
+  scheme string
+  // one cipher per KID in use
+  blocks map[KID]cipher.Block
+  entries []SampleEncryptionEntry
+  info []*CencSampleEncryptionInfoEntry
+}
+
+// SetDecryptionKeys makes the sample reader of an encrypted track return
+// clear samples. Each sample is decrypted with the key of its KID, which
+// comes from its seig sample group or else from tenc, so keys is expected
+// to hold all of them, as 16 byte AES-128 keys. All four common encryption
+// schemes are supported: cenc and cens (AES-CTR), cbc1 and cbcs (AES-CBC).
+func (t *Track) SetDecryptionKeys(keys map[KID][]byte) error {
+  if (t.err != nil) {
+    return t.err;
+  }
+
+  sinf,err := t.ProtectionInfo();
+
+  if (err != nil) {
+    return err;
+  }
+
+  switch (sinf.Schm.SchemeType) {
+  case CENC_SCHEME_CENC, CENC_SCHEME_CENS, CENC_SCHEME_CBC1, CENC_SCHEME_CBCS:
+  default:
+    return fmt.Errorf("unsupported protection scheme %q", sinf.Schm.SchemeType);
+  }
+
+  info,err := t.SampleEncryptionInfo();
+
+  if (err != nil) {
+    return err;
+  }
+
+  blocks := map[KID]cipher.Block{};
+
+  for _,p := range info {
+    if _,ok := blocks[p.KID]; ok || p.IsProtected == 0 {
+      continue;
+    }
+
+    key,ok := keys[p.KID];
+
+    if (!ok) {
+      return fmt.Errorf("no key for KID %s", p.KID);
+    }
+
+    if (len(key) != 16) {
+      return fmt.Errorf("key for KID %s must be 16 bytes", p.KID);
+    }
+
+    block,err := aes.NewCipher(key);
+
+    if (err != nil) {
+      return err;
+    }
+
+    blocks[p.KID] = block;
+  }
+
+  entries,err := t.SampleEncryption();
+
+  if (err != nil) {
+    return err;
+  }
+
+  t.decrypter = &sampleDecrypter{
+    scheme: sinf.Schm.SchemeType,
+    blocks: blocks,
+    entries: entries,
+    info: info,
+  };
+
+  return nil;
+}
+
+// SetDecryptionKeys sets the keys on every encrypted track of the movie.
+func (m *Movie) SetDecryptionKeys(keys map[KID][]byte) error {
+  for _,t := range m.Tracks {
+    if _,err := t.ProtectionInfo(); err != nil {
+      continue;
+    }
+    err := t.SetDecryptionKeys(keys);
+    if (err != nil) {
+      return err;
+    }
+  }
+  return nil;
+}
+
+// protectedRanges returns the [start, end) ranges of the encrypted bytes
+// of a sample, one per subsample.
+func protectedRanges(e *SampleEncryptionEntry, size int) ([][2]int, error) {
+  if (len(e.Subsamples) == 0) {
+    return [][2]int{{0, size}}, nil;
+  }
+
+  var ranges [][2]int;
+  pos := 0;
+
+  for _,sub := range e.Subsamples {
+    start := pos + int(sub.BytesOfClearData);
+    end := start + int(sub.BytesOfProtectedData);
+    if (end > size) {
+      return nil, errors.New("subsamples exceed sample size");
+    }
+    ranges = append(ranges, [2]int{start, end});
+    pos = end;
+  }
+
+  return ranges, nil;
+}
+
+// forEachPatternBlock calls fn for every run of encrypted blocks in
+// data, following the crypt:skip pattern. A zero pattern means all full
+// blocks are encrypted, trailing partial blocks are always left clear.
+func forEachPatternBlock(data []byte, cryptBlocks int, skipBlocks int, fn func(blocks []byte)) {
+  full := len(data) - len(data) % aes.BlockSize;
+
+  if (cryptBlocks == 0 && skipBlocks == 0) {
+    if (full > 0) {
+      fn(data[:full]);
+    }
+    return;
+  }
+
+  crypt := cryptBlocks * aes.BlockSize;
+  skip := skipBlocks * aes.BlockSize;
+
+  for pos := 0; pos < full; pos += crypt + skip {
+    end := pos + crypt;
+    if (end > full) {
+      end = full;
+    }
+    fn(data[pos:end]);
+  }
+}
+
+func (d *sampleDecrypter) decryptSample(i int, data []byte) error {
+  if (i >= len(d.entries)) {
+    return errors.New("sample has no encryption info");
+  }
+
+  e := &d.entries[i];
+  p := d.info[i];
+
+  // SampleEncryption only leaves the IV of unprotected samples empty
+  if (p.IsProtected == 0 || len(e.IV) == 0) {
+    return nil;
+  }
+
+  block := d.blocks[p.KID];
+  cryptBlocks := int(p.CryptByteBlock);
+  skipBlocks := int(p.SkipByteBlock);
+
+  ranges,err := protectedRanges(e, len(data));
+
+  if (err != nil) {
+    return err;
+  }
+
+  iv := make([]byte, aes.BlockSize);
+  copy(iv, e.IV);
+
+  switch (d.scheme) {
+  case CENC_SCHEME_CENC:
+    // the counter runs over the protected bytes of the whole sample
+    stream := cipher.NewCTR(block, iv);
+    for _,r := range ranges {
+      stream.XORKeyStream(data[r[0]:r[1]], data[r[0]:r[1]]);
+    }
+  case CENC_SCHEME_CENS:
+    stream := cipher.NewCTR(block, iv);
+    for _,r := range ranges {
+      forEachPatternBlock(data[r[0]:r[1]], cryptBlocks, skipBlocks, func(blocks []byte) {
+        stream.XORKeyStream(blocks, blocks);
+      });
+    }
+  case CENC_SCHEME_CBC1:
+    // the chain runs over the protected bytes of the whole sample, only
+    // complete blocks are encrypted
+    mode := cipher.NewCBCDecrypter(block, iv);
+    var prot []byte;
+    for _,r := range ranges {
+      prot = append(prot, data[r[0]:r[1]]...);
+    }
+    full := len(prot) - len(prot) % aes.BlockSize;
+    mode.CryptBlocks(prot[:full], prot[:full]);
+    for _,r := range ranges {
+      n := copy(data[r[0]:r[1]], prot);
+      prot = prot[n:];
+    }
+  case CENC_SCHEME_CBCS:
+    // the IV is reset for every subsample
+    for _,r := range ranges {
+      mode := cipher.NewCBCDecrypter(block, iv);
+      forEachPatternBlock(data[r[0]:r[1]], cryptBlocks, skipBlocks, func(blocks []byte) {
+        mode.CryptBlocks(blocks, blocks);
+      });
+    }
+  }
+
+  return nil;
+}
@@ -0,0 +1,132 @@
+package mp4
+
+import (
+  "os"
+  "bytes"
+  "testing"
+  "crypto/aes"
+  "crypto/cipher"
+  "encoding/hex"
+  "path/filepath"
+)
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+  b,err := hex.DecodeString(s);
+  if (err != nil) {
+    t.Fatal(err);
+  }
+  return b;
+}
+
+// The expected samples were computed independently of this package, with
+// AES-128-ECB from openssl and the counter, chaining and pattern rules of
+// ISO/IEC 23001-7 applied by hand.
+func TestDecryptKnownAnswer(t *testing.T) {
+  key := mustDecodeHex(t, "2b7e151628aed2a6abf7158809cf4f3c");
+  clear := mustDecodeHex(t, "0b30557a9fc4e90e33587da2c7ec11365b80a5caef14395e83a8cdf2173c6186abd0f51a3f6489aed3f81d42678cb1d6fb20456a8fb4d9fe23486d92b7dc01264b7095badf04294e7398bde2072c51769bc0e50a2f54799ec3e80d32577ca1c6eb10355a7fa4c9ee");
+  // both subsamples end on a partial block
+  subsamples := []SubsampleEntry{
+    {BytesOfClearData: 5, BytesOfProtectedData: 48},
+    {BytesOfClearData: 11, BytesOfProtectedData: 40},
+  };
+
+  tests := []struct {
+    scheme string
+    iv string
+    cryptBlocks uint8
+    skipBlocks uint8
+    encrypted string
+  }{
+    {CENC_SCHEME_CENC, "f0f1f2f3f4f5f6f7", 0, 0, "0b30557a9fc8c6b58502a4c5edf5efcb6e1b5681edbb96c27142149f24dcc4477d9974aaf147a90edf6c16033e7ad2122daf2c2c6db4d9fe23486d92b7dc012692673cce4b1e99da48cb1968cc16cf2e87412e8427a33537af1313cb5729dfe9a90dd11c8bcce203"},
+    {CENC_SCHEME_CENS, "f0f1f2f3f4f5f6f7", 1, 1, "0b30557a9fc8c6b58502a4c5edf5efcb6e1b5681ed14395e83a8cdf2173c6186abd0f51a3fcb26322112c42f546c14172d69c4da41b4d9fe23486d92b7dc0126685035b64b0f681785fb7934884517949bc0e50a2f54799ec3e80d32577ca1c6eb10355a7fa4c9ee"},
+    {CENC_SCHEME_CBC1, "000102030405060708090a0b0c0d0e0f", 0, 0, "0b30557a9f156728c3e7acfbc639e89303e5596f9e1e4e64a260c04d1066a4da776c760fa758b17c11f1d61757684ac2c971ff19c5b4d9fe23486d92b7dc01268cd599fc9647aa0a82d052bbdc542138634758ca2e48cb925be7ab801d1936a2eb10355a7fa4c9ee"},
+    {CENC_SCHEME_CBCS, "000102030405060708090a0b0c0d0e0f", 1, 1, "0b30557a9f156728c3e7acfbc639e89303e5596f9e14395e83a8cdf2173c6186abd0f51a3f33cc850a192f4540fad033d709e0c04db4d9fe23486d92b7dc0126583eeb8c5c76290ce58419e86c076b879bc0e50a2f54799ec3e80d32577ca1c6eb10355a7fa4c9ee"},
+  };
+
+  block,err := aes.NewCipher(key);
+  if (err != nil) {
+    t.Fatal(err);
+  }
+
+  kid := KID{0x01};
+
+  for _,tc := range tests {
+    d := sampleDecrypter{
+      scheme: tc.scheme,
+      blocks: map[KID]cipher.Block{kid: block},
+      entries: []SampleEncryptionEntry{{IV: mustDecodeHex(t, tc.iv), Subsamples: subsamples}},
+      info: []*CencSampleEncryptionInfoEntry{{IsProtected: 1, KID: kid, CryptByteBlock: tc.cryptBlocks, SkipByteBlock: tc.skipBlocks}},
+    };
+
+    data := mustDecodeHex(t, tc.encrypted);
+
+    err = d.decryptSample(0, data);
+    if (err != nil) {
+      t.Fatalf("%s: %v", tc.scheme, err);
+    }
+
+    if (!bytes.Equal(data, clear)) {
+      t.Errorf("%s: got %x, want %x", tc.scheme, data, clear);
+    }
+  }
+}
+
+func TestSetDecryptionKeysKeySize(t *testing.T) {
+  dir := t.TempDir();
+  clearPath := filepath.Join(dir, "clear.mp4");
+  encPath := filepath.Join(dir, "enc.mp4");
+
+  err := writeTestMovie(clearPath, []testTrack{audioTestTrack()});
+  if (err != nil) {
+    t.Fatal(err);
+  }
+
+  f,err := os.Open(clearPath);
+  if (err != nil) {
+    t.Fatal(err);
+  }
+  defer f.Close();
+
+  out,err := os.Create(encPath);
+  if (err != nil) {
+    t.Fatal(err);
+  }
+
+  kid := KID{0x02};
+  key := bytes.Repeat([]byte{0x5a}, 16);
+
+  err = Encrypt(out, f, EncryptionConfig{Scheme: CENC_SCHEME_CENC, KID: kid, Key: key});
+  out.Close();
+  if (err != nil) {
+    t.Fatal(err);
+  }
+
+  ef,err := os.Open(encPath);
+  if (err != nil) {
+    t.Fatal(err);
+  }
+  defer ef.Close();
+
+  res,err := Parse(ef);
+  if (err != nil) {
+    t.Fatal(err);
+  }
+
+  m,err := res.Movie();
+  if (err != nil) {
+    t.Fatal(err);
+  }
+
+  // AES-192 and AES-256 keys are valid for crypto/aes but not for CENC
+  for _,n := range []int{8, 24, 32} {
+    err = m.Tracks[0].SetDecryptionKeys(map[KID][]byte{kid: bytes.Repeat([]byte{0x5a}, n)});
+    if (err == nil) {
+      t.Errorf("%d byte key accepted", n);
+    }
+  }
+
+  err = m.Tracks[0].SetDecryptionKeys(map[KID][]byte{kid: key});
+  if (err != nil) {
+    t.Error(err);
+  }
+}
@@ -0,0 +1,115 @@
+package mp4
+
+import (
+  "fmt"
+)
+
+// full bandwidth channels in front and surround positions for each acmod
+var acmodChannels = [8][2]int{
+  {2, 0}, // 1+1 dual mono
+  {1, 0},
+  {2, 0},
+  {3, 0},
+  {2, 1},
+  {3, 1},
+  {2, 2},
+  {3, 2},
+}
+
+// channels added by each chan_loc bit of a dependent substream, MSB first:
+// Lc/Rc, Lrs/Rrs, Cs, Ts, Lsd/Rsd, Lw/Rw, Lvh/Rvh, Cvh, LFE2
+var chanLocChannels = [9]int{2, 2, 1, 1, 2, 2, 2, 1, 1};
+
+func acmodLayout(acmod uint8, lfe int, extra int) string {
+  ch := acmodChannels[acmod & 0x07];
+  return fmt.Sprintf("%d.%d", ch[0] + ch[1] + extra, lfe);
+}
+
+func (dac3 AC3SpecificBox) ChannelCount() int {
+  ch := acmodChannels[dac3.Acmod & 0x07];
+  n := ch[0] + ch[1];
+
+  if (dac3.Lfeon) {
+    n++;
+  }
+
+  return n;
+}
+
+// ChannelLayout returns the layout in the usual "5.1" notation.
+func (dac3 AC3SpecificBox) ChannelLayout() string {
+  lfe := 0;
+
+  if (dac3.Lfeon) {
+    lfe = 1;
+  }
+
+  return acmodLayout(dac3.Acmod, lfe, 0);
+}
+
+func (dec3 EC3SpecificBox) extraChannels() (int, bool) {
+  if (len(dec3.Substreams) == 0) {
+    return 0, false;
+  }
+
+  sub := dec3.Substreams[0];
+  extra := 0;
+  lfe2 := false;
+
+  for i := 0; i < 9; i++ {
+    if ((sub.ChanLoc >> uint(8 - i)) & 0x01 == 0) {
+      continue;
+    }
+    if (i == 8) {
+      lfe2 = true;
+      continue;
+    }
+    extra += chanLocChannels[i];
+  }
+
+  return extra, lfe2;
+}
+
+// ChannelCount returns the number of channels of the first independent
+// substream including its dependent substreams. Object based (JOC) content
+// reports its channel bed.
+func (dec3 EC3SpecificBox) ChannelCount() int {
+  if (len(dec3.Substreams) == 0) {
+    return 0;
+  }
+
+  sub := dec3.Substreams[0];
+  ch := acmodChannels[sub.Acmod & 0x07];
+  extra,lfe2 := dec3.extraChannels();
+  n := ch[0] + ch[1] + extra;
+
+  if (sub.Lfeon) {
+    n++;
+  }
+
+  if (lfe2) {
+    n++;
+  }
+
+  return n;
+}
+
+func (dec3 EC3SpecificBox) ChannelLayout() string {
+  if (len(dec3.Substreams) == 0) {
+    return "";
+  }
+
+  sub := dec3.Substreams[0];
+  extra,lfe2 := dec3.extraChannels();
+  lfe := 0;
+
+  if (sub.Lfeon) {
+    lfe++;
+  }
+
+  if (lfe2) {
+    lfe++;
+  }
+
+  return acmodLayout(sub.Acmod, lfe, extra);
+}
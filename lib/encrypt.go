@@ -0,0 +1,969 @@
+package mp4
+
+import (
+  "io"
+  "os"
+  "fmt"
+  "sort"
+  "errors"
+  "crypto/aes"
+  "crypto/rand"
+  "crypto/cipher"
+  "encoding/binary"
+)
+
+// EncryptionConfig holds the parameters Encrypt protects a file with.
+type EncryptionConfig struct {
+  // CENC_SCHEME_CENC or CENC_SCHEME_CBCS
+  Scheme string
+  KID KID
+  // AES-128 content key
+  Key []byte
+  // written after the ClearKey pssh, e.g. to carry the init data of other
+  // DRM systems
+  Pssh []ProtectionSystemSpecificHeaderBox
+}
+
+type trackEncrypter struct {
+  track *Track
+  scheme string
+  block cipher.Block
+  // encv or enca
+  entryType string
+  // NAL unit length size, zero for full sample encryption
+  lenSize int
+  hevc bool
+  // parameter sets by id, from the sample entry then in band, needed to
+  // find the end of the slice headers
+  avcSPS map[uint32]*H264SPS
+  avcPPS map[uint32]*H264PPS
+  hevcSPS map[uint32]*H265SPS
+  hevcPPS map[uint32]*H265PPS
+  ivSize int
+  constantIV []byte
+  cryptBlocks int
+  skipBlocks int
+  entries []SampleEncryptionEntry
+}
+
+type topLevelBox struct {
+  Box
+  offset uint64
+}
+
+type offsetRef struct {
+  // position of the field in the moov buffer
+  pos int
+  offset uint64
+  // 64 bit field, from co64
+  large bool
+}
+
+// boxWriter serializes boxes into a single buffer, the size of a box is
+// filled in when it is closed.
+type boxWriter struct {
+  buf []byte
+}
+
+func (bw *boxWriter) u8(v uint8) {
+  bw.buf = append(bw.buf, v);
+}
+
+func (bw *boxWriter) u16(v uint16) {
+  bw.buf = append(bw.buf, byte(v >> 8), byte(v));
+}
+
+func (bw *boxWriter) u32(v uint32) {
+  bw.buf = append(bw.buf, byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v));
+}
+
+func (bw *boxWriter) bytes(data []byte) {
+  bw.buf = append(bw.buf, data...);
+}
+
+func (bw *boxWriter) start(typ string) int {
+  pos := len(bw.buf);
+  bw.u32(0);
+  bw.bytes([]byte(typ));
+  return pos;
+}
+
+func (bw *boxWriter) startFull(typ string, version uint8, flags uint32) int {
+  pos := bw.start(typ);
+  bw.u32(uint32(version) << 24 | flags & 0xffffff);
+  return pos;
+}
+
+func (bw *boxWriter) end(pos int) {
+  binary.BigEndian.PutUint32(bw.buf[pos:], uint32(len(bw.buf) - pos));
+}
+
+func (bw *boxWriter) copyBox(b *Box, payload []byte) {
+  pos := bw.start(b.Type);
+  bw.bytes(payload);
+  bw.end(pos);
+}
+
+type packager struct {
+  cfg *EncryptionConfig
+  tracks []*trackEncrypter
+  bw boxWriter
+  chunkOffsets []offsetRef
+  // saio fields, offset being the position of the aux info in the buffer
+  auxOffsets []offsetRef
+}
+
+// scanBoxes lists the top level boxes of a file.
+func scanBoxes(r io.ReaderAt, size uint64) ([]topLevelBox, error) {
+  var boxes []topLevelBox;
+
+  hdr := make([]byte, BOX_HDR_SZ_EXT);
+
+  for off := uint64(0); off < size; {
+    n,_ := r.ReadAt(hdr, int64(off));
+
+    if (n < BOX_HDR_SZ) {
+      return nil, errors.New("not enough data");
+    }
+
+    b,_ := parseBox(hdr);
+
+    if (b.headerSize > uint64(n)) {
+      return nil, errors.New("not enough data");
+    }
+
+    // a zero size box extends to the end of the file
+    if (b.Size == 0) {
+      b.Size = size - off;
+    }
+
+    if (b.Size < b.headerSize || b.Size > size - off) {
+      return nil, errors.New("invalid box size");
+    }
+
+    boxes = append(boxes, topLevelBox{Box: *b, offset: off});
+
+    off += b.Size;
+  }
+
+  return boxes, nil;
+}
+
+func newTrackEncrypter(t *Track, cfg *EncryptionConfig, block cipher.Block) (*trackEncrypter, error) {
+  kind := t.Kind();
+
+  if (kind != TRACK_KIND_VIDEO && kind != TRACK_KIND_AUDIO) {
+    return nil, nil;
+  }
+
+  if (t.Err() != nil) {
+    return nil, fmt.Errorf("track %d: %v", t.ID(), t.Err());
+  }
+
+  entry,err := t.SampleEntry();
+
+  if (err != nil) {
+    return nil, err;
+  }
+
+  if (protectionInfo(entry) != nil) {
+    return nil, fmt.Errorf("track %d is already encrypted", t.ID());
+  }
+
+  enc := trackEncrypter{track: t, scheme: cfg.Scheme, block: block, entryType: "enca"};
+
+  if (kind == TRACK_KIND_VIDEO) {
+    enc.entryType = "encv";
+
+    // video is encrypted per NAL unit, other codecs need their own
+    // subsample layout
+    switch (entry.Box.Type) {
+    case "avc1", "avc3", "dva1", "dvav":
+      ext := findExtension(entry, func(ext interface{}) bool {
+        _,ok := ext.(AVCcBox);
+        return ok;
+      });
+      if (ext == nil) {
+        return nil, errors.New("avcC box not found");
+      }
+      avcc := ext.(AVCcBox);
+      enc.lenSize = int(avcc.SizeLen);
+      enc.avcSPS = map[uint32]*H264SPS{};
+      enc.avcPPS = map[uint32]*H264PPS{};
+      for _,nalus := range [][][]byte{avcc.SPS, avcc.PPS} {
+        for _,nalu := range nalus {
+          err = enc.addParamSet(nalu);
+          if (err != nil) {
+            return nil, fmt.Errorf("avcC: %v", err);
+          }
+        }
+      }
+    case "hvc1", "hev1", "dvh1", "dvhe":
+      ext := findExtension(entry, func(ext interface{}) bool {
+        _,ok := ext.(HVCcBox);
+        return ok;
+      });
+      if (ext == nil) {
+        return nil, errors.New("hvcC box not found");
+      }
+      hvcc := ext.(HVCcBox);
+      enc.lenSize = int(hvcc.SizeLen);
+      enc.hevc = true;
+      enc.hevcSPS = map[uint32]*H265SPS{};
+      enc.hevcPPS = map[uint32]*H265PPS{};
+      for _,nalus := range [][][]byte{hvcc.SPS, hvcc.PPS} {
+        for _,nalu := range nalus {
+          err = enc.addParamSet(nalu);
+          if (err != nil) {
+            return nil, fmt.Errorf("hvcC: %v", err);
+          }
+        }
+      }
+    default:
+      return nil, fmt.Errorf("encrypting %q video is not supported", entry.Box.Type);
+    }
+  }
+
+  var iv []byte;
+
+  if (cfg.Scheme == CENC_SCHEME_CENC) {
+    // 8 byte IVs incremented for each sample, the lower half of the
+    // counter block counts the blocks within the sample
+    enc.ivSize = 8;
+    iv = make([]byte, 8);
+  } else {
+    enc.constantIV = make([]byte, 16);
+    iv = enc.constantIV;
+    // pattern encryption only applies to video
+    if (kind == TRACK_KIND_VIDEO) {
+      enc.cryptBlocks = 1;
+      enc.skipBlocks = 9;
+    }
+  }
+
+  _,err = rand.Read(iv);
+
+  if (err != nil) {
+    return nil, err;
+  }
+
+  base := binary.BigEndian.Uint64(iv[0:8]);
+
+  enc.entries = make([]SampleEncryptionEntry, t.SampleCount());
+
+  for i := range enc.entries {
+    e := &enc.entries[i];
+
+    if (enc.ivSize > 0) {
+      e.IV = make([]byte, 8);
+      binary.BigEndian.PutUint64(e.IV, base + uint64(i));
+    }
+
+    if (enc.lenSize == 0) {
+      continue;
+    }
+
+    s,err := t.ReadSample(i);
+
+    if (err != nil) {
+      return nil, err;
+    }
+
+    e.Subsamples,err = enc.nalSubsamples(s.Data);
+
+    if (err != nil) {
+      return nil, fmt.Errorf("sample %d: %v", i + 1, err);
+    }
+  }
+
+  return &enc, nil;
+}
+
+func (enc *trackEncrypter) isVCL(nal []byte) bool {
+  if (enc.hevc) {
+    return ((nal[0] >> 1) & 0x3f) < 32;
+  }
+
+  typ := nal[0] & 0x1f;
+
+  return typ >= 1 && typ <= 5;
+}
+
+// addParamSet keeps the SPS and PPS NAL units, other ones are ignored.
+func (enc *trackEncrypter) addParamSet(nal []byte) error {
+  if (len(nal) == 0) {
+    return nil;
+  }
+
+  if (enc.hevc) {
+    switch ((nal[0] >> 1) & 0x3f) {
+    case 33:
+      sps,err := ParseH265SPS(nal);
+      if (err != nil) {
+        return err;
+      }
+      enc.hevcSPS[sps.Id] = sps;
+    case 34:
+      pps,err := ParseH265PPS(nal);
+      if (err != nil) {
+        return err;
+      }
+      enc.hevcPPS[pps.Id] = pps;
+    }
+    return nil;
+  }
+
+  switch (nal[0] & 0x1f) {
+  case 7:
+    sps,err := ParseH264SPS(nal);
+    if (err != nil) {
+      return err;
+    }
+    enc.avcSPS[sps.Id] = sps;
+  case 8:
+    pps,err := ParseH264PPS(nal, enc.avcSPS[h264PPSSPSId(nal)]);
+    if (err != nil) {
+      return err;
+    }
+    enc.avcPPS[pps.Id] = pps;
+  }
+
+  return nil;
+}
+
+func (enc *trackEncrypter) sliceHeaderSize(nal []byte) (int, error) {
+  if (enc.hevc) {
+    return h265SliceHeaderSize(nal, enc.hevcSPS, enc.hevcPPS);
+  }
+  return h264SliceHeaderSize(nal, enc.avcSPS, enc.avcPPS);
+}
+
+func appendSubsample(subs []SubsampleEntry, clear int, protected int) []SubsampleEntry {
+  // the clear byte count is only 16 bits wide
+  for clear > 0xffff {
+    subs = append(subs, SubsampleEntry{BytesOfClearData: 0xffff});
+    clear -= 0xffff;
+  }
+
+  return append(subs, SubsampleEntry{BytesOfClearData: uint16(clear), BytesOfProtectedData: uint32(protected)});
+}
+
+// nalSubsamples maps the NAL units of a sample to subsamples. Only the
+// slice data of VCL NAL units is protected, their NAL unit and slice
+// headers are left in the clear. With cenc the protected part is trimmed
+// down to a whole number of blocks.
+func (enc *trackEncrypter) nalSubsamples(data []byte) ([]SubsampleEntry, error) {
+  var subs []SubsampleEntry;
+  clear := 0;
+
+  for len(data) > 0 {
+    if (len(data) < enc.lenSize) {
+      return nil, errors.New("truncated NAL unit");
+    }
+
+    size := 0;
+    for _,b := range data[:enc.lenSize] {
+      size = size << 8 | int(b);
+    }
+
+    total := enc.lenSize + size;
+
+    if (total > len(data)) {
+      return nil, errors.New("invalid NAL unit size");
+    }
+
+    nal := data[enc.lenSize:total];
+    protected := 0;
+
+    if (size > 0 && enc.isVCL(nal)) {
+      hdr,err := enc.sliceHeaderSize(nal);
+      if (err != nil) {
+        return nil, err;
+      }
+      protected = size - hdr;
+      if (enc.scheme == CENC_SCHEME_CENC) {
+        protected -= protected % aes.BlockSize;
+      }
+    } else {
+      err := enc.addParamSet(nal);
+      if (err != nil) {
+        return nil, err;
+      }
+    }
+
+    clear += total - protected;
+
+    if (protected > 0) {
+      subs = appendSubsample(subs, clear, protected);
+      clear = 0;
+    }
+
+    data = data[total:];
+  }
+
+  if (clear > 0) {
+    subs = appendSubsample(subs, clear, 0);
+  }
+
+  return subs, nil;
+}
+
+func (enc *trackEncrypter) encryptSample(i int, data []byte) error {
+  e := &enc.entries[i];
+
+  ranges,err := protectedRanges(e, len(data));
+
+  if (err != nil) {
+    return err;
+  }
+
+  iv := make([]byte, aes.BlockSize);
+
+  if (enc.ivSize > 0) {
+    copy(iv, e.IV);
+  } else {
+    copy(iv, enc.constantIV);
+  }
+
+  switch (enc.scheme) {
+  case CENC_SCHEME_CENC:
+    stream := cipher.NewCTR(enc.block, iv);
+    for _,r := range ranges {
+      stream.XORKeyStream(data[r[0]:r[1]], data[r[0]:r[1]]);
+    }
+  case CENC_SCHEME_CBCS:
+    for _,r := range ranges {
+      mode := cipher.NewCBCEncrypter(enc.block, iv);
+      forEachPatternBlock(data[r[0]:r[1]], enc.cryptBlocks, enc.skipBlocks, func(blocks []byte) {
+        mode.CryptBlocks(blocks, blocks);
+      });
+    }
+  }
+
+  return nil;
+}
+
+func (p *packager) writeProtectionSchemeInfo(enc *trackEncrypter, format string) {
+  bw := &p.bw;
+
+  sinf := bw.start("sinf");
+
+  frma := bw.start("frma");
+  bw.bytes([]byte(format));
+  bw.end(frma);
+
+  schm := bw.startFull("schm", 0, 0);
+  bw.bytes([]byte(enc.scheme));
+  bw.u32(0x00010000);
+  bw.end(schm);
+
+  schi := bw.start("schi");
+
+  // the pattern fields only exist in version 1
+  var version uint8;
+  if (enc.scheme == CENC_SCHEME_CBCS) {
+    version = 1;
+  }
+
+  tenc := bw.startFull("tenc", version, 0);
+  bw.u8(0);
+  bw.u8(uint8(enc.cryptBlocks << 4 | enc.skipBlocks));
+  bw.u8(1);
+  bw.u8(uint8(enc.ivSize));
+  bw.bytes(p.cfg.KID[:]);
+  if (enc.ivSize == 0) {
+    bw.u8(uint8(len(enc.constantIV)));
+    bw.bytes(enc.constantIV);
+  }
+  bw.end(tenc);
+
+  bw.end(schi);
+  bw.end(sinf);
+}
+
+func (p *packager) writeSampleDescBox(b *Box, data []byte, enc *trackEncrypter) error {
+  if (len(data) < 8) {
+    return errors.New("invalid stsd box");
+  }
+
+  pos := p.bw.start(b.Type);
+  p.bw.bytes(data[:8]);
+
+  err := iterBoxes(data[8:], func(b *Box, payload []byte) {
+    entry := p.bw.start(enc.entryType);
+    p.bw.bytes(payload);
+    p.writeProtectionSchemeInfo(enc, b.Type);
+    p.bw.end(entry);
+  });
+
+  p.bw.end(pos);
+
+  return err;
+}
+
+func (p *packager) writeChunkOffsetBox(b *Box, data []byte) error {
+  if (len(data) < 8) {
+    return errors.New("invalid stco box");
+  }
+
+  count := int(binary.BigEndian.Uint32(data[4:8]));
+
+  if (len(data) < 8 + count * 4) {
+    return errors.New("invalid stco box");
+  }
+
+  pos := p.bw.start(b.Type);
+  p.bw.bytes(data[:8]);
+
+  // offsets are filled in once the new layout is known
+  for i := 0; i < count; i++ {
+    off := binary.BigEndian.Uint32(data[8 + i * 4:]);
+    p.chunkOffsets = append(p.chunkOffsets, offsetRef{pos: len(p.bw.buf), offset: uint64(off)});
+    p.bw.u32(0);
+  }
+
+  p.bw.end(pos);
+
+  return nil;
+}
+
+func (p *packager) writeChunkLargeOffsetBox(b *Box, data []byte) error {
+  if (len(data) < 8) {
+    return errors.New("invalid co64 box");
+  }
+
+  count := int(binary.BigEndian.Uint32(data[4:8]));
+
+  if (len(data) < 8 + count * 8) {
+    return errors.New("invalid co64 box");
+  }
+
+  pos := p.bw.start(b.Type);
+  p.bw.bytes(data[:8]);
+
+  for i := 0; i < count; i++ {
+    off := binary.BigEndian.Uint64(data[8 + i * 8:]);
+    p.chunkOffsets = append(p.chunkOffsets, offsetRef{pos: len(p.bw.buf), offset: off, large: true});
+    p.bw.u32(0);
+    p.bw.u32(0);
+  }
+
+  p.bw.end(pos);
+
+  return nil;
+}
+
+// writeSampleEncryption adds senc to the sample table along with the saiz
+// and saio boxes pointing at its entries.
+func (p *packager) writeSampleEncryption(enc *trackEncrypter) error {
+  bw := &p.bw;
+
+  sizes := make([]int, len(enc.entries));
+  defaultSize := -1;
+  total := 0;
+
+  for i,e := range enc.entries {
+    sizes[i] = len(e.IV);
+    if (enc.lenSize > 0) {
+      sizes[i] += 2 + 6 * len(e.Subsamples);
+    }
+    if (sizes[i] > 0xff) {
+      return fmt.Errorf("sample %d: too many subsamples", i + 1);
+    }
+    if (i == 0) {
+      defaultSize = sizes[i];
+    } else if (sizes[i] != defaultSize) {
+      defaultSize = 0;
+    }
+    total += sizes[i];
+  }
+
+  // a constant IV without subsamples needs no auxiliary information
+  if (total == 0) {
+    return nil;
+  }
+
+  saiz := bw.startFull("saiz", 0, 0);
+  bw.u8(uint8(defaultSize));
+  bw.u32(uint32(len(sizes)));
+  if (defaultSize == 0) {
+    for _,size := range sizes {
+      bw.u8(uint8(size));
+    }
+  }
+  bw.end(saiz);
+
+  saio := bw.startFull("saio", 0, 0);
+  bw.u32(1);
+  ref := offsetRef{pos: len(bw.buf)};
+  bw.u32(0);
+  bw.end(saio);
+
+  var flags uint32;
+  if (enc.lenSize > 0) {
+    flags = 0x02;
+  }
+
+  senc := bw.startFull("senc", 0, flags);
+  bw.u32(uint32(len(enc.entries)));
+
+  ref.offset = uint64(len(bw.buf));
+  p.auxOffsets = append(p.auxOffsets, ref);
+
+  for _,e := range enc.entries {
+    bw.bytes(e.IV);
+    if (enc.lenSize > 0) {
+      bw.u16(uint16(len(e.Subsamples)));
+      for _,sub := range e.Subsamples {
+        bw.u16(sub.BytesOfClearData);
+        bw.u32(sub.BytesOfProtectedData);
+      }
+    }
+  }
+
+  bw.end(senc);
+
+  return nil;
+}
+
+func (p *packager) writeProtectionSystemSpecificHeader(pssh *ProtectionSystemSpecificHeaderBox) {
+  bw := &p.bw;
+
+  var version uint8;
+  if (len(pssh.KIDs) > 0) {
+    version = 1;
+  }
+
+  pos := bw.startFull("pssh", version, 0);
+  bw.bytes(pssh.SystemID[:]);
+  if (version > 0) {
+    bw.u32(uint32(len(pssh.KIDs)));
+    for _,kid := range pssh.KIDs {
+      bw.bytes(kid[:]);
+    }
+  }
+  bw.u32(uint32(len(pssh.Data)));
+  bw.bytes(pssh.Data);
+  bw.end(pos);
+}
+
+// writeTrackBox copies a trak box, descending into the containers leading
+// to the sample table to rewrite stsd and the chunk offsets.
+func (p *packager) writeTrackBox(b *Box, data []byte, enc *trackEncrypter) error {
+  pos := p.bw.start(b.Type);
+
+  var perr error;
+
+  err := iterBoxes(data, func(b *Box, data []byte) {
+    if (perr != nil) {
+      return;
+    }
+    switch (b.Type) {
+    case "mdia", "minf", "stbl":
+      perr = p.writeTrackBox(b, data, enc);
+    case "stsd":
+      if (enc != nil) {
+        perr = p.writeSampleDescBox(b, data, enc);
+      } else {
+        p.bw.copyBox(b, data);
+      }
+    case "stco":
+      perr = p.writeChunkOffsetBox(b, data);
+    case "co64":
+      perr = p.writeChunkLargeOffsetBox(b, data);
+    default:
+      p.bw.copyBox(b, data);
+    }
+  });
+
+  if (err == nil) {
+    err = perr;
+  }
+
+  if (err != nil) {
+    return err;
+  }
+
+  if (b.Type == "stbl" && enc != nil) {
+    err = p.writeSampleEncryption(enc);
+    if (err != nil) {
+      return err;
+    }
+  }
+
+  p.bw.end(pos);
+
+  return nil;
+}
+
+func (p *packager) writeMovieBox(b *Box, data []byte) error {
+  pos := p.bw.start(b.Type);
+
+  trak := 0;
+  var perr error;
+
+  err := iterBoxes(data, func(b *Box, data []byte) {
+    if (perr != nil) {
+      return;
+    }
+    if (b.Type != "trak") {
+      p.bw.copyBox(b, data);
+      return;
+    }
+    if (trak >= len(p.tracks)) {
+      perr = errors.New("track count mismatch");
+      return;
+    }
+    perr = p.writeTrackBox(b, data, p.tracks[trak]);
+    trak++;
+  });
+
+  if (err == nil) {
+    err = perr;
+  }
+
+  if (err != nil) {
+    return err;
+  }
+
+  clearKey := ProtectionSystemSpecificHeaderBox{SystemID: SYSTEM_ID_CLEARKEY, KIDs: []KID{p.cfg.KID}};
+
+  p.writeProtectionSystemSpecificHeader(&clearKey);
+
+  for i := range p.cfg.Pssh {
+    p.writeProtectionSystemSpecificHeader(&p.cfg.Pssh[i]);
+  }
+
+  p.bw.end(pos);
+
+  return nil;
+}
+
+func copyRange(w io.Writer, r io.ReaderAt, start uint64, end uint64) error {
+  _,err := io.Copy(w, io.NewSectionReader(r, int64(start), int64(end - start)));
+  return err;
+}
+
+// Encrypt writes a copy of f to w with its audio and video tracks protected
+// using Common Encryption, either with the cenc or the cbcs scheme. Sample
+// entries become encv/enca with a sinf box, the IVs and subsample maps are
+// stored in senc with saiz/saio pointing at them and the moov box gets a
+// ClearKey pssh followed by the ones in cfg. AVC and HEVC are encrypted per
+// NAL unit, audio samples as a whole. Fragmented files are not supported.
+//
+// The slice headers are left in the clear as the subsample rules require,
+// they are parsed with the parameter sets of avcC/hvcC and the ones sent in
+// band, so slices referring to an unknown parameter set fail.
+func Encrypt(w io.Writer, f *os.File, cfg EncryptionConfig) error {
+  if (cfg.Scheme != CENC_SCHEME_CENC && cfg.Scheme != CENC_SCHEME_CBCS) {
+    return fmt.Errorf("unsupported protection scheme %q", cfg.Scheme);
+  }
+
+  if (len(cfg.Key) != 16) {
+    return errors.New("content key must be 16 bytes");
+  }
+
+  block,err := aes.NewCipher(cfg.Key);
+
+  if (err != nil) {
+    return err;
+  }
+
+  _,err = f.Seek(0, io.SeekStart);
+
+  if (err != nil) {
+    return err;
+  }
+
+  res,err := Parse(f);
+
+  if (err != nil) {
+    return err;
+  }
+
+  movie,err := res.Movie();
+
+  if (err != nil) {
+    return err;
+  }
+
+  info,err := f.Stat();
+
+  if (err != nil) {
+    return err;
+  }
+
+  boxes,err := scanBoxes(f, uint64(info.Size()));
+
+  if (err != nil) {
+    return err;
+  }
+
+  moovIdx := -1;
+
+  for i,b := range boxes {
+    switch (b.Type) {
+    case "moov":
+      moovIdx = i;
+    case "moof":
+      return errors.New("fragmented files are not supported");
+    }
+  }
+
+  if (moovIdx < 0) {
+    return errors.New("moov box not found");
+  }
+
+  p := packager{cfg: &cfg, tracks: make([]*trackEncrypter, len(movie.Tracks))};
+  encrypted := 0;
+
+  for i,t := range movie.Tracks {
+    p.tracks[i],err = newTrackEncrypter(t, &cfg, block);
+    if (err != nil) {
+      return err;
+    }
+    if (p.tracks[i] != nil) {
+      encrypted++;
+    }
+  }
+
+  if (encrypted == 0) {
+    return errors.New("no audio or video tracks to encrypt");
+  }
+
+  moov := boxes[moovIdx];
+  data := make([]byte, moov.Size - moov.headerSize);
+
+  _,err = f.ReadAt(data, int64(moov.offset + moov.headerSize));
+
+  if (err != nil) {
+    return err;
+  }
+
+  err = p.writeMovieBox(&moov.Box, data);
+
+  if (err != nil) {
+    return err;
+  }
+
+  // lay out the boxes again now that the size of moov is known
+  newOffsets := make([]uint64, len(boxes));
+  pos := uint64(0);
+
+  for i,b := range boxes {
+    newOffsets[i] = pos;
+    if (i == moovIdx) {
+      pos += uint64(len(p.bw.buf));
+    } else {
+      pos += b.Size;
+    }
+  }
+
+  for _,ref := range p.chunkOffsets {
+    off := ref.offset;
+    found := false;
+    for i,b := range boxes {
+      if (i != moovIdx && off >= b.offset && off < b.offset + b.Size) {
+        off = off - b.offset + newOffsets[i];
+        found = true;
+        break;
+      }
+    }
+    if (!found) {
+      return fmt.Errorf("invalid chunk offset %d", ref.offset);
+    }
+    if (ref.large) {
+      binary.BigEndian.PutUint64(p.bw.buf[ref.pos:], off);
+      continue;
+    }
+    if (off > 0xffffffff) {
+      return errors.New("chunk offset overflow, the file needs co64");
+    }
+    binary.BigEndian.PutUint32(p.bw.buf[ref.pos:], uint32(off));
+  }
+
+  for _,ref := range p.auxOffsets {
+    off := newOffsets[moovIdx] + ref.offset;
+    if (off > 0xffffffff) {
+      return errors.New("auxiliary information offset overflow");
+    }
+    binary.BigEndian.PutUint32(p.bw.buf[ref.pos:], uint32(off));
+  }
+
+  type sampleRef struct {
+    info *sampleInfo
+    enc *trackEncrypter
+    index int
+  }
+
+  var samples []sampleRef;
+
+  for _,enc := range p.tracks {
+    if (enc == nil) {
+      continue;
+    }
+    for i := range enc.track.samples {
+      samples = append(samples, sampleRef{info: &enc.track.samples[i], enc: enc, index: i});
+    }
+  }
+
+  sort.Slice(samples, func(i, j int) bool {
+    return samples[i].info.offset < samples[j].info.offset;
+  });
+
+  next := 0;
+
+  for i,b := range boxes {
+    if (i == moovIdx) {
+      _,err = w.Write(p.bw.buf);
+      if (err != nil) {
+        return err;
+      }
+      continue;
+    }
+
+    pos := b.offset;
+    end := b.offset + b.Size;
+
+    for ; next < len(samples) && samples[next].info.offset < end; next++ {
+      s := samples[next];
+
+      if (s.info.offset < pos || s.info.offset + uint64(s.info.size) > end) {
+        return errors.New("samples overlap or cross box boundaries");
+      }
+
+      err = copyRange(w, f, pos, s.info.offset);
+      if (err != nil) {
+        return err;
+      }
+
+      sample,err := s.enc.track.ReadSample(s.index);
+      if (err != nil) {
+        return err;
+      }
+
+      err = s.enc.encryptSample(s.index, sample.Data);
+      if (err != nil) {
+        return err;
+      }
+
+      _,err = w.Write(sample.Data);
+      if (err != nil) {
+        return err;
+      }
+
+      pos = s.info.offset + uint64(s.info.size);
+    }
+
+    err = copyRange(w, f, pos, end);
+    if (err != nil) {
+      return err;
+    }
+  }
+
+  if (next < len(samples)) {
+    return errors.New("sample data outside of the file");
+  }
+
+  return nil;
+}
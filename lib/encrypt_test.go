@@ -0,0 +1,886 @@
+package mp4
+
+import (
+  "os"
+  "bytes"
+  "testing"
+  "path/filepath"
+  "encoding/binary"
+)
+
+// bitWriter builds the RBSP of test NAL units, MSB first.
+type bitWriter struct {
+  buf []byte
+  n int
+}
+
+func (bw *bitWriter) bits(n int, v uint64) {
+  for i := n - 1; i >= 0; i-- {
+    if (bw.n % 8 == 0) {
+      bw.buf = append(bw.buf, 0);
+    }
+    if ((v >> uint(i)) & 0x01 == 1) {
+      bw.buf[len(bw.buf) - 1] |= 0x80 >> uint(bw.n % 8);
+    }
+    bw.n++;
+  }
+}
+
+func (bw *bitWriter) flag(v bool) {
+  if (v) {
+    bw.bits(1, 1);
+  } else {
+    bw.bits(1, 0);
+  }
+}
+
+func (bw *bitWriter) ue(v uint32) {
+  x := uint64(v) + 1;
+  lz := 0;
+  for (x >> uint(lz)) > 1 {
+    lz++;
+  }
+  bw.bits(lz, 0);
+  bw.bits(lz + 1, x);
+}
+
+func (bw *bitWriter) se(v int32) {
+  if (v > 0) {
+    bw.ue(uint32(2 * v - 1));
+  } else {
+    bw.ue(uint32(-2 * v));
+  }
+}
+
+// headerSize returns the number of bytes holding the bits written so far.
+func (bw *bitWriter) headerSize() int {
+  return (bw.n + 7) / 8;
+}
+
+// body appends n bytes of filler slice data.
+func (bw *bitWriter) body(n int, seed int) {
+  for i := 0; i < n; i++ {
+    bw.bits(8, uint64(seed * 31 + i * 7));
+  }
+}
+
+// nal adds the rbsp_trailing_bits and returns the NAL unit with its header
+// and emulation prevention bytes.
+func (bw *bitWriter) nal(header ...byte) []byte {
+  bw.bits(1, 1);
+  for bw.n % 8 != 0 {
+    bw.bits(1, 0);
+  }
+
+  res := append([]byte{}, header...);
+  zeros := 0;
+
+  for _,b := range bw.buf {
+    if (zeros >= 2 && b <= 0x03) {
+      res = append(res, 0x03);
+      zeros = 0;
+    }
+    res = append(res, b);
+    if (b == 0x00) {
+      zeros++;
+    } else {
+      zeros = 0;
+    }
+  }
+
+  return res;
+}
+
+type testSample struct {
+  data []byte
+  // [start, end) ranges which have to stay in the clear
+  clear [][2]int
+  // end of the slice headers, where protected data starts
+  slices []int
+}
+
+// addNAL appends a NAL unit with a 4 byte length, the first header bytes
+// of it are expected to be left in the clear.
+func (s *testSample) addNAL(nal []byte, header int) {
+  s.clear = append(s.clear, [2]int{len(s.data), len(s.data) + 4 + header});
+  if (header < len(nal)) {
+    s.slices = append(s.slices, len(s.data) + 4 + header);
+  }
+  s.data = append(s.data, byte(len(nal) >> 24), byte(len(nal) >> 16), byte(len(nal) >> 8), byte(len(nal)));
+  s.data = append(s.data, nal...);
+}
+
+type testTrack struct {
+  handler string
+  entry []byte
+  samples []testSample
+}
+
+func writeVisualSampleEntry(bw *boxWriter, typ string, config []byte) {
+  pos := bw.start(typ);
+  bw.bytes(make([]byte, 6));
+  bw.u16(1);
+  bw.bytes(make([]byte, 16));
+  bw.u16(64);
+  bw.u16(64);
+  bw.u32(0x480000);
+  bw.u32(0x480000);
+  bw.u32(0);
+  bw.u16(1);
+  bw.bytes(make([]byte, 32));
+  bw.u16(24);
+  bw.u16(0xffff);
+  bw.bytes(config);
+  bw.end(pos);
+}
+
+func h264TestSPS() []byte {
+  bw := bitWriter{};
+  // Main profile, level 3
+  bw.bits(8, 77);
+  bw.bits(8, 0);
+  bw.bits(8, 30);
+  bw.ue(0);
+  // log2_max_frame_num_minus4, pic_order_cnt_type and
+  // log2_max_pic_order_cnt_lsb_minus4
+  bw.ue(0);
+  bw.ue(0);
+  bw.ue(2);
+  bw.ue(2);
+  bw.flag(false);
+  bw.ue(3);
+  bw.ue(3);
+  // frame_mbs_only_flag, direct_8x8_inference_flag, frame_cropping_flag
+  // and vui_parameters_present_flag
+  bw.flag(true);
+  bw.flag(true);
+  bw.flag(false);
+  bw.flag(false);
+  return bw.nal(0x67);
+}
+
+func h264TestPPS(id uint32, cabac bool) []byte {
+  bw := bitWriter{};
+  bw.ue(id);
+  bw.ue(0);
+  bw.flag(cabac);
+  // bottom_field_pic_order_in_frame_present_flag
+  bw.flag(cabac);
+  bw.ue(0);
+  bw.ue(1);
+  bw.ue(0);
+  // weighted_pred_flag and weighted_bipred_idc
+  bw.flag(cabac);
+  if (cabac) {
+    bw.bits(2, 1);
+  } else {
+    bw.bits(2, 0);
+  }
+  bw.se(0);
+  bw.se(0);
+  bw.se(0);
+  // deblocking_filter_control_present_flag
+  bw.flag(cabac);
+  bw.flag(false);
+  bw.flag(false);
+  return bw.nal(0x68);
+}
+
+// avcTestTrack covers IDR, P and B slices with reference list
+// modifications, weight tables and memory management operations, and an
+// in band PPS.
+func avcTestTrack() testTrack {
+  sps := h264TestSPS();
+  pps := h264TestPPS(0, true);
+
+  bw := boxWriter{};
+  avcc := bw.start("avcC");
+  bw.bytes([]byte{1, 77, 0, 30, 0xff, 0xe1});
+  bw.u16(uint16(len(sps)));
+  bw.bytes(sps);
+  bw.u8(1);
+  bw.u16(uint16(len(pps)));
+  bw.bytes(pps);
+  bw.end(avcc);
+
+  entry := boxWriter{};
+  writeVisualSampleEntry(&entry, "avc1", bw.buf);
+
+  tr := testTrack{handler: "vide", entry: entry.buf};
+
+  // IDR slice after an in band PPS using CAVLC
+  s := testSample{};
+  inband := h264TestPPS(1, false);
+  s.addNAL(inband, len(inband));
+  w := bitWriter{};
+  w.ue(0);
+  w.ue(7);
+  w.ue(0);
+  w.bits(4, 0);
+  w.ue(0);
+  w.bits(6, 0);
+  w.se(0);
+  w.bits(2, 0);
+  w.se(-3);
+  w.ue(0);
+  w.se(1);
+  w.se(-1);
+  hdr := w.headerSize();
+  w.body(300, 1);
+  s.addNAL(w.nal(0x65), 1 + hdr);
+  tr.samples = append(tr.samples, s);
+
+  // P slice, two references with a list modification and weights
+  s = testSample{};
+  w = bitWriter{};
+  w.ue(0);
+  w.ue(5);
+  w.ue(0);
+  w.bits(4, 1);
+  w.bits(6, 2);
+  w.se(0);
+  w.flag(true);
+  w.ue(1);
+  w.flag(true);
+  w.ue(0);
+  w.ue(0);
+  w.ue(3);
+  w.ue(5);
+  w.ue(4);
+  w.flag(true);
+  w.se(3);
+  w.se(-2);
+  w.flag(true);
+  w.se(1);
+  w.se(2);
+  w.se(-1);
+  w.se(-2);
+  w.flag(false);
+  w.flag(false);
+  w.flag(true);
+  w.ue(1);
+  w.ue(0);
+  w.ue(0);
+  w.ue(1);
+  w.se(2);
+  w.ue(1);
+  hdr = w.headerSize();
+  w.body(200, 2);
+  s.addNAL(w.nal(0x41), 1 + hdr);
+  tr.samples = append(tr.samples, s);
+
+  // non reference B slice with an implicit L1 weight
+  s = testSample{};
+  w = bitWriter{};
+  w.ue(0);
+  w.ue(6);
+  w.ue(0);
+  w.bits(4, 2);
+  w.bits(6, 4);
+  w.se(0);
+  w.flag(true);
+  w.flag(false);
+  w.flag(false);
+  w.flag(false);
+  w.ue(2);
+  w.ue(2);
+  for i := 0; i < 4; i++ {
+    w.flag(false);
+  }
+  w.flag(true);
+  w.se(1);
+  w.se(1);
+  w.flag(false);
+  w.ue(0);
+  w.se(0);
+  w.ue(2);
+  w.se(0);
+  w.se(0);
+  hdr = w.headerSize();
+  w.body(150, 3);
+  s.addNAL(w.nal(0x01), 1 + hdr);
+  tr.samples = append(tr.samples, s);
+
+  // P slice using the in band PPS, two slices in the sample
+  s = testSample{};
+  for i := 0; i < 2; i++ {
+    w = bitWriter{};
+    w.ue(uint32(i * 8));
+    w.ue(0);
+    w.ue(1);
+    w.bits(4, 3);
+    w.bits(6, 6);
+    w.flag(false);
+    w.flag(false);
+    w.flag(false);
+    w.se(0);
+    hdr = w.headerSize();
+    w.body(80, 4 + i);
+    s.addNAL(w.nal(0x41), 1 + hdr);
+  }
+  tr.samples = append(tr.samples, s);
+
+  return tr;
+}
+
+func h265TestSPS() []byte {
+  bw := bitWriter{};
+  bw.bits(4, 0);
+  bw.bits(3, 0);
+  bw.flag(true);
+  // profile_tier_level, Main profile, level 3.1
+  bw.bits(2, 0);
+  bw.flag(false);
+  bw.bits(5, 1);
+  bw.bits(32, 0x60000000);
+  bw.bits(4, 0x09);
+  bw.bits(43, 0);
+  bw.bits(1, 0);
+  bw.bits(8, 93);
+  bw.ue(0);
+  bw.ue(1);
+  bw.ue(64);
+  bw.ue(64);
+  bw.flag(false);
+  bw.ue(0);
+  bw.ue(0);
+  // 8 bit POC LSBs
+  bw.ue(4);
+  bw.flag(true);
+  bw.ue(4);
+  bw.ue(2);
+  bw.ue(0);
+  // 16x16 CTBs, a 4x4 picture of them
+  bw.ue(0);
+  bw.ue(1);
+  bw.ue(0);
+  bw.ue(2);
+  bw.ue(1);
+  bw.ue(1);
+  bw.flag(false);
+  bw.flag(true);
+  bw.flag(true);
+  bw.flag(false);
+  // two short term sets, {-1} and {-1, -2}
+  bw.ue(2);
+  bw.ue(1);
+  bw.ue(0);
+  bw.ue(0);
+  bw.flag(true);
+  bw.flag(false);
+  bw.ue(2);
+  bw.ue(0);
+  bw.ue(0);
+  bw.flag(true);
+  bw.ue(0);
+  bw.flag(true);
+  // two long term candidates, only the first one used
+  bw.flag(true);
+  bw.ue(2);
+  bw.bits(8, 0x10);
+  bw.flag(true);
+  bw.bits(8, 0x20);
+  bw.flag(false);
+  bw.flag(true);
+  bw.flag(true);
+  bw.flag(false);
+  bw.flag(false);
+  return bw.nal(0x42, 0x01);
+}
+
+func h265TestPPS() []byte {
+  bw := bitWriter{};
+  bw.ue(0);
+  bw.ue(0);
+  // dependent slices, output flag, one extra slice header bit
+  bw.flag(true);
+  bw.flag(true);
+  bw.bits(3, 1);
+  bw.flag(false);
+  bw.flag(true);
+  bw.ue(0);
+  bw.ue(0);
+  bw.se(0);
+  bw.flag(false);
+  bw.flag(false);
+  bw.flag(false);
+  bw.se(0);
+  bw.se(0);
+  // slice chroma QP offsets, weighted prediction
+  bw.flag(true);
+  bw.flag(true);
+  bw.flag(false);
+  bw.flag(false);
+  // entropy coding sync, loop filter across slices
+  bw.flag(false);
+  bw.flag(true);
+  bw.flag(true);
+  // deblocking override enabled
+  bw.flag(true);
+  bw.flag(true);
+  bw.flag(false);
+  bw.se(0);
+  bw.se(0);
+  bw.flag(false);
+  // lists modification, slice header extension
+  bw.flag(true);
+  bw.ue(0);
+  bw.flag(true);
+  bw.flag(false);
+  return bw.nal(0x44, 0x01);
+}
+
+func appendHVCcArray(bw *boxWriter, typ uint8, nalu []byte) {
+  bw.u8(0x80 | typ);
+  bw.u16(1);
+  bw.u16(uint16(len(nalu)));
+  bw.bytes(nalu);
+}
+
+// hevcTestTrack covers IDR, P and B slice segments with entry points,
+// header extensions, long term pictures, list modifications, weight tables,
+// a slice coded reference picture set and a dependent slice segment.
+func hevcTestTrack() testTrack {
+  bw := boxWriter{};
+  hvcc := bw.start("hvcC");
+  bw.bytes([]byte{1, 0x01, 0x60, 0, 0, 0, 0x90, 0, 0, 0, 0, 0, 93, 0xf0, 0, 0xfc, 0xfd, 0xf8, 0xf8, 0, 0, 0x0f, 2});
+  appendHVCcArray(&bw, 33, h265TestSPS());
+  appendHVCcArray(&bw, 34, h265TestPPS());
+  bw.end(hvcc);
+
+  entry := boxWriter{};
+  writeVisualSampleEntry(&entry, "hvc1", bw.buf);
+
+  tr := testTrack{handler: "vide", entry: entry.buf};
+
+  // IDR_W_RADL
+  s := testSample{};
+  w := bitWriter{};
+  w.flag(true);
+  w.flag(false);
+  w.ue(0);
+  w.bits(1, 0);
+  w.ue(2);
+  w.flag(true);
+  w.flag(true);
+  w.flag(true);
+  w.se(1);
+  w.se(0);
+  w.se(0);
+  w.flag(true);
+  w.flag(false);
+  w.se(1);
+  w.se(-1);
+  w.flag(true);
+  w.ue(2);
+  w.ue(3);
+  w.bits(4, 5);
+  w.bits(4, 9);
+  w.ue(1);
+  w.bits(8, 0xaa);
+  w.flag(true);
+  for w.n % 8 != 0 {
+    w.flag(false);
+  }
+  hdr := w.headerSize();
+  w.body(300, 5);
+  s.addNAL(w.nal(0x26, 0x01), 2 + hdr);
+  tr.samples = append(tr.samples, s);
+
+  // TRAIL_R P slice followed by a dependent slice segment
+  s = testSample{};
+  w = bitWriter{};
+  w.flag(true);
+  w.ue(0);
+  w.bits(1, 0);
+  w.ue(1);
+  w.flag(true);
+  w.bits(8, 1);
+  w.flag(true);
+  w.bits(1, 1);
+  w.ue(1);
+  w.ue(1);
+  w.bits(1, 0);
+  w.flag(false);
+  w.bits(8, 0x30);
+  w.flag(true);
+  w.flag(true);
+  w.ue(1);
+  w.flag(true);
+  w.flag(false);
+  w.flag(true);
+  // three references out of NumPicTotalCurr 4
+  w.flag(true);
+  w.ue(2);
+  w.flag(true);
+  w.bits(2, 3);
+  w.bits(2, 0);
+  w.bits(2, 2);
+  w.flag(true);
+  w.ue(1);
+  w.ue(3);
+  w.se(-1);
+  w.flag(true);
+  w.flag(false);
+  w.flag(true);
+  w.flag(false);
+  w.flag(true);
+  w.flag(false);
+  w.se(2);
+  w.se(-3);
+  for i := 0; i < 4; i++ {
+    w.se(int32(i));
+  }
+  w.se(-1);
+  w.se(1);
+  w.ue(0);
+  w.se(-2);
+  w.se(1);
+  w.se(-1);
+  w.flag(false);
+  w.flag(false);
+  w.ue(0);
+  w.ue(0);
+  w.flag(true);
+  for w.n % 8 != 0 {
+    w.flag(false);
+  }
+  hdr = w.headerSize();
+  w.body(200, 6);
+  s.addNAL(w.nal(0x02, 0x01), 2 + hdr);
+
+  w = bitWriter{};
+  w.flag(false);
+  w.ue(0);
+  w.flag(true);
+  w.bits(4, 5);
+  w.ue(0);
+  w.ue(0);
+  w.flag(true);
+  for w.n % 8 != 0 {
+    w.flag(false);
+  }
+  hdr = w.headerSize();
+  w.body(100, 7);
+  s.addNAL(w.nal(0x02, 0x01), 2 + hdr);
+  tr.samples = append(tr.samples, s);
+
+  // TRAIL_N B slice with a reference picture set predicted in the header
+  s = testSample{};
+  w = bitWriter{};
+  w.flag(true);
+  w.ue(0);
+  w.bits(1, 0);
+  w.ue(0);
+  w.flag(false);
+  w.bits(8, 3);
+  w.flag(false);
+  w.flag(true);
+  w.ue(0);
+  w.flag(false);
+  w.ue(0);
+  w.flag(true);
+  w.flag(false);
+  w.flag(false);
+  w.flag(true);
+  w.ue(0);
+  w.ue(0);
+  w.flag(false);
+  w.flag(true);
+  w.flag(false);
+  w.flag(true);
+  w.ue(0);
+  w.ue(1);
+  w.flag(true);
+  w.flag(false);
+  w.ue(1);
+  w.se(0);
+  w.se(0);
+  w.se(0);
+  w.flag(true);
+  w.flag(true);
+  w.flag(true);
+  w.ue(1);
+  w.ue(0);
+  w.bits(1, 1);
+  w.ue(0);
+  w.flag(true);
+  for w.n % 8 != 0 {
+    w.flag(false);
+  }
+  hdr = w.headerSize();
+  w.body(120, 8);
+  s.addNAL(w.nal(0x00, 0x01), 2 + hdr);
+  tr.samples = append(tr.samples, s);
+
+  return tr;
+}
+
+func audioTestTrack() testTrack {
+  bw := boxWriter{};
+  pos := bw.start("mp4a");
+  bw.bytes(make([]byte, 6));
+  bw.u16(1);
+  bw.bytes(make([]byte, 8));
+  bw.u16(2);
+  bw.u16(16);
+  bw.bytes(make([]byte, 4));
+  bw.u32(48000 << 16);
+  esds := bw.startFull("esds", 0, 0);
+  bw.bytes([]byte{3, 25, 0, 2, 0, 4, 17, 0x40, 0x15, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 5, 2, 0x12, 0x10, 6, 1, 2});
+  bw.end(esds);
+  bw.end(pos);
+
+  tr := testTrack{handler: "soun", entry: bw.buf};
+
+  for i := 0; i < 4; i++ {
+    data := make([]byte, 100 + i * 37);
+    for j := range data {
+      data[j] = byte(i * 13 + j);
+    }
+    tr.samples = append(tr.samples, testSample{data: data});
+  }
+
+  return tr;
+}
+
+// writeTestMovie writes a non fragmented file holding the tracks, one chunk
+// each.
+func writeTestMovie(path string, tracks []testTrack) error {
+  bw := boxWriter{};
+
+  ftyp := bw.start("ftyp");
+  bw.bytes([]byte("isom"));
+  bw.u32(512);
+  bw.bytes([]byte("isom"));
+  bw.end(ftyp);
+
+  moov := bw.start("moov");
+
+  mvhd := bw.startFull("mvhd", 0, 0);
+  bw.u32(0);
+  bw.u32(0);
+  bw.u32(1000);
+  bw.u32(4000);
+  bw.u32(0x10000);
+  bw.u16(0x100);
+  bw.bytes(make([]byte, 10));
+  for _,v := range []uint32{0x10000, 0, 0, 0, 0x10000, 0, 0, 0, 0x40000000} {
+    bw.u32(v);
+  }
+  bw.bytes(make([]byte, 24));
+  bw.u32(uint32(len(tracks) + 1));
+  bw.end(mvhd);
+
+  chunkOffsets := make([]int, len(tracks));
+
+  for i,tr := range tracks {
+    n := uint32(len(tr.samples));
+
+    trak := bw.start("trak");
+
+    tkhd := bw.startFull("tkhd", 0, 3);
+    bw.u32(0);
+    bw.u32(0);
+    bw.u32(uint32(i + 1));
+    bw.u32(0);
+    bw.u32(n * 1000);
+    bw.bytes(make([]byte, 52));
+    bw.u32(64 << 16);
+    bw.u32(64 << 16);
+    bw.end(tkhd);
+
+    mdia := bw.start("mdia");
+
+    mdhd := bw.startFull("mdhd", 0, 0);
+    bw.u32(0);
+    bw.u32(0);
+    bw.u32(1000);
+    bw.u32(n * 1000);
+    bw.u16(0x55c4);
+    bw.u16(0);
+    bw.end(mdhd);
+
+    hdlr := bw.startFull("hdlr", 0, 0);
+    bw.u32(0);
+    bw.bytes([]byte(tr.handler));
+    bw.bytes(make([]byte, 13));
+    bw.end(hdlr);
+
+    minf := bw.start("minf");
+    stbl := bw.start("stbl");
+
+    stsd := bw.startFull("stsd", 0, 0);
+    bw.u32(1);
+    bw.bytes(tr.entry);
+    bw.end(stsd);
+
+    stts := bw.startFull("stts", 0, 0);
+    bw.u32(1);
+    bw.u32(n);
+    bw.u32(1000);
+    bw.end(stts);
+
+    stsc := bw.startFull("stsc", 0, 0);
+    bw.u32(1);
+    bw.u32(1);
+    bw.u32(n);
+    bw.u32(1);
+    bw.end(stsc);
+
+    stsz := bw.startFull("stsz", 0, 0);
+    bw.u32(0);
+    bw.u32(n);
+    for _,s := range tr.samples {
+      bw.u32(uint32(len(s.data)));
+    }
+    bw.end(stsz);
+
+    stco := bw.startFull("stco", 0, 0);
+    bw.u32(1);
+    chunkOffsets[i] = len(bw.buf);
+    bw.u32(0);
+    bw.end(stco);
+
+    bw.end(stbl);
+    bw.end(minf);
+    bw.end(mdia);
+    bw.end(trak);
+  }
+
+  bw.end(moov);
+
+  mdat := bw.start("mdat");
+
+  for i,tr := range tracks {
+    binary.BigEndian.PutUint32(bw.buf[chunkOffsets[i]:], uint32(len(bw.buf)));
+    for _,s := range tr.samples {
+      bw.bytes(s.data);
+    }
+  }
+
+  bw.end(mdat);
+
+  return os.WriteFile(path, bw.buf, 0644);
+}
+
+func testEncryptRoundTrip(t *testing.T, scheme string) {
+  dir := t.TempDir();
+  tracks := []testTrack{avcTestTrack(), hevcTestTrack(), audioTestTrack()};
+
+  clearPath := filepath.Join(dir, "clear.mp4");
+  encPath := filepath.Join(dir, "enc.mp4");
+
+  err := writeTestMovie(clearPath, tracks);
+  if (err != nil) {
+    t.Fatal(err);
+  }
+
+  f,err := os.Open(clearPath);
+  if (err != nil) {
+    t.Fatal(err);
+  }
+  defer f.Close();
+
+  out,err := os.Create(encPath);
+  if (err != nil) {
+    t.Fatal(err);
+  }
+
+  kid := KID{0x10, 0x77, 0xef, 0xec, 0xc0, 0xb2, 0x4d, 0x02, 0xac, 0xe3, 0x3c, 0x1e, 0x52, 0xe2, 0xfb, 0x4b};
+  key := []byte{0x3a, 0x2a, 0x1b, 0x68, 0xdd, 0x2b, 0xd9, 0xb2, 0xee, 0xb2, 0x5e, 0x84, 0xc4, 0x77, 0x6f, 0xa9};
+
+  err = Encrypt(out, f, EncryptionConfig{Scheme: scheme, KID: kid, Key: key});
+  out.Close();
+  if (err != nil) {
+    t.Fatal(err);
+  }
+
+  ef,err := os.Open(encPath);
+  if (err != nil) {
+    t.Fatal(err);
+  }
+  defer ef.Close();
+
+  res,err := Parse(ef);
+  if (err != nil) {
+    t.Fatal(err);
+  }
+
+  m,err := res.Movie();
+  if (err != nil) {
+    t.Fatal(err);
+  }
+
+  if (len(m.Tracks) != len(tracks)) {
+    t.Fatalf("got %d tracks, want %d", len(m.Tracks), len(tracks));
+  }
+
+  for i,tr := range tracks {
+    sinf,err := m.Tracks[i].ProtectionInfo();
+    if (err != nil) {
+      t.Fatalf("track %d: %v", i + 1, err);
+    }
+    if (sinf.Schm.SchemeType != scheme) {
+      t.Errorf("track %d: scheme %q, want %q", i + 1, sinf.Schm.SchemeType, scheme);
+    }
+    entries,err := m.Tracks[i].SampleEncryption();
+    if (err != nil) {
+      t.Fatalf("track %d: %v", i + 1, err);
+    }
+    for j,want := range tr.samples {
+      if (len(want.slices) > 0) {
+        ranges,err := protectedRanges(&entries[j], len(want.data));
+        if (err != nil) {
+          t.Fatalf("track %d sample %d: %v", i + 1, j + 1, err);
+        }
+        if (len(ranges) != len(want.slices)) {
+          t.Fatalf("track %d sample %d: %d subsamples, want %d", i + 1, j + 1, len(ranges), len(want.slices));
+        }
+        // cenc moves the odd bytes of the slice data in the clear
+        for k,r := range ranges {
+          if (r[0] < want.slices[k] || r[0] >= want.slices[k] + 16 || (scheme == CENC_SCHEME_CBCS && r[0] != want.slices[k])) {
+            t.Errorf("track %d sample %d: slice %d protected from %d, header ends at %d", i + 1, j + 1, k + 1, r[0], want.slices[k]);
+          }
+        }
+      }
+      s,err := m.Tracks[i].ReadSample(j);
+      if (err != nil) {
+        t.Fatalf("track %d sample %d: %v", i + 1, j + 1, err);
+      }
+      if (bytes.Equal(s.Data, want.data)) {
+        t.Errorf("track %d sample %d: not encrypted", i + 1, j + 1);
+      }
+      for _,r := range want.clear {
+        if (!bytes.Equal(s.Data[r[0]:r[1]], want.data[r[0]:r[1]])) {
+          t.Errorf("track %d sample %d: bytes %d-%d are encrypted", i + 1, j + 1, r[0], r[1]);
+        }
+      }
+    }
+  }
+
+  err = m.SetDecryptionKeys(map[KID][]byte{kid: key});
+  if (err != nil) {
+    t.Fatal(err);
+  }
+
+  for i,tr := range tracks {
+    for j,want := range tr.samples {
+      s,err := m.Tracks[i].ReadSample(j);
+      if (err != nil) {
+        t.Fatalf("track %d sample %d: %v", i + 1, j + 1, err);
+      }
+      if (!bytes.Equal(s.Data, want.data)) {
+        t.Errorf("track %d sample %d: decrypted data differs", i + 1, j + 1);
+      }
+    }
+  }
+}
+
+func TestEncryptCENC(t *testing.T) {
+  testEncryptRoundTrip(t, CENC_SCHEME_CENC);
+}
+
+func TestEncryptCBCS(t *testing.T) {
+  testEncryptRoundTrip(t, CENC_SCHEME_CBCS);
+}
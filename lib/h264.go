@@ -0,0 +1,712 @@
+package mp4
+
+import (
+  "fmt"
+  "errors"
+)
+
+var h264SampleAspectRatios = [][2]uint16{
+  {0, 0}, {1, 1}, {12, 11}, {10, 11}, {16, 11}, {40, 33}, {24, 11}, {20, 11},
+  {32, 11}, {80, 33}, {18, 11}, {15, 11}, {64, 33}, {160, 99}, {4, 3}, {3, 2}, {2, 1},
+};
+
+type H264HRD struct {
+  CpbCount uint32 `json:"cpbCount"`
+  BitRateScale uint8 `json:"bitRateScale"`
+  CpbSizeScale uint8 `json:"cpbSizeScale"`
+  BitRate []uint32 `json:"bitRate"`
+  CpbSize []uint32 `json:"cpbSize"`
+  CBR []bool `json:"cbr"`
+}
+
+type H264VUI struct {
+  AspectRatioInfoPresent bool `json:"aspectRatioInfoPresent"`
+  AspectRatioIdc uint8 `json:"aspectRatioIdc"`
+  SarWidth uint16 `json:"sarWidth"`
+  SarHeight uint16 `json:"sarHeight"`
+  OverscanInfoPresent bool `json:"overscanInfoPresent"`
+  OverscanAppropriate bool `json:"overscanAppropriate"`
+  VideoSignalTypePresent bool `json:"videoSignalTypePresent"`
+  VideoFormat uint8 `json:"videoFormat"`
+  VideoFullRange bool `json:"videoFullRange"`
+  ColourDescriptionPresent bool `json:"colourDescriptionPresent"`
+  ColourPrimaries uint8 `json:"colourPrimaries"`
+  TransferCharacteristics uint8 `json:"transferCharacteristics"`
+  MatrixCoefficients uint8 `json:"matrixCoefficients"`
+  ChromaLocInfoPresent bool `json:"chromaLocInfoPresent"`
+  ChromaSampleLocTop uint32 `json:"chromaSampleLocTop"`
+  ChromaSampleLocBottom uint32 `json:"chromaSampleLocBottom"`
+  TimingInfoPresent bool `json:"timingInfoPresent"`
+  NumUnitsInTick uint32 `json:"numUnitsInTick"`
+  TimeScale uint32 `json:"timeScale"`
+  FixedFrameRate bool `json:"fixedFrameRate"`
+  NalHRD *H264HRD `json:"nalHrd"`
+  VclHRD *H264HRD `json:"vclHrd"`
+  LowDelayHRD bool `json:"lowDelayHrd"`
+  PicStructPresent bool `json:"picStructPresent"`
+  BitstreamRestriction bool `json:"bitstreamRestriction"`
+  MotionVectorsOverPicBoundaries bool `json:"motionVectorsOverPicBoundaries"`
+  MaxBytesPerPicDenom uint32 `json:"maxBytesPerPicDenom"`
+  MaxBitsPerMbDenom uint32 `json:"maxBitsPerMbDenom"`
+  Log2MaxMvLengthHorizontal uint32 `json:"log2MaxMvLengthHorizontal"`
+  Log2MaxMvLengthVertical uint32 `json:"log2MaxMvLengthVertical"`
+  MaxNumReorderFrames uint32 `json:"maxNumReorderFrames"`
+  MaxDecFrameBuffering uint32 `json:"maxDecFrameBuffering"`
+}
+
+type H264SPS struct {
+  Profile uint8 `json:"profile"`
+  ConstraintFlags uint8 `json:"constraintFlags"`
+  Level uint8 `json:"level"`
+  Id uint32 `json:"id"`
+  ChromaFormat uint32 `json:"chromaFormat"`
+  SeparateColourPlane bool `json:"separateColourPlane"`
+  BitDepthLuma uint32 `json:"bitDepthLuma"`
+  BitDepthChroma uint32 `json:"bitDepthChroma"`
+  QpprimeYZeroTransformBypass bool `json:"qpprimeYZeroTransformBypass"`
+  ScalingMatrixPresent bool `json:"scalingMatrixPresent"`
+  Log2MaxFrameNum uint32 `json:"log2MaxFrameNum"`
+  PicOrderCntType uint32 `json:"picOrderCntType"`
+  Log2MaxPicOrderCntLsb uint32 `json:"log2MaxPicOrderCntLsb"`
+  DeltaPicOrderAlwaysZero bool `json:"deltaPicOrderAlwaysZero"`
+  OffsetForNonRefPic int32 `json:"offsetForNonRefPic"`
+  OffsetForTopToBottomField int32 `json:"offsetForTopToBottomField"`
+  OffsetForRefFrame []int32 `json:"offsetForRefFrame"`
+  MaxNumRefFrames uint32 `json:"maxNumRefFrames"`
+  GapsInFrameNumAllowed bool `json:"gapsInFrameNumAllowed"`
+  PicWidthInMbs uint32 `json:"picWidthInMbs"`
+  PicHeightInMapUnits uint32 `json:"picHeightInMapUnits"`
+  FrameMbsOnly bool `json:"frameMbsOnly"`
+  MbAdaptiveFrameField bool `json:"mbAdaptiveFrameField"`
+  Direct8x8Inference bool `json:"direct8x8Inference"`
+  FrameCropping bool `json:"frameCropping"`
+  CropLeft uint32 `json:"cropLeft"`
+  CropRight uint32 `json:"cropRight"`
+  CropTop uint32 `json:"cropTop"`
+  CropBottom uint32 `json:"cropBottom"`
+  CodedWidth uint32 `json:"codedWidth"`
+  CodedHeight uint32 `json:"codedHeight"`
+  Width uint32 `json:"width"`
+  Height uint32 `json:"height"`
+  VUI *H264VUI `json:"vui"`
+}
+
+type H264PPS struct {
+  Id uint32 `json:"id"`
+  SPSId uint32 `json:"spsId"`
+  EntropyCodingMode bool `json:"entropyCodingMode"`
+  BottomFieldPicOrderInFramePresent bool `json:"bottomFieldPicOrderInFramePresent"`
+  NumSliceGroups uint32 `json:"numSliceGroups"`
+  SliceGroupMapType uint32 `json:"sliceGroupMapType"`
+  SliceGroupChangeRate uint32 `json:"sliceGroupChangeRate"`
+  NumRefIdxL0DefaultActive uint32 `json:"numRefIdxL0DefaultActive"`
+  NumRefIdxL1DefaultActive uint32 `json:"numRefIdxL1DefaultActive"`
+  WeightedPred bool `json:"weightedPred"`
+  WeightedBipredIdc uint8 `json:"weightedBipredIdc"`
+  PicInitQp int32 `json:"picInitQp"`
+  PicInitQs int32 `json:"picInitQs"`
+  ChromaQpIndexOffset int32 `json:"chromaQpIndexOffset"`
+  DeblockingFilterControlPresent bool `json:"deblockingFilterControlPresent"`
+  ConstrainedIntraPred bool `json:"constrainedIntraPred"`
+  RedundantPicCntPresent bool `json:"redundantPicCntPresent"`
+  Transform8x8Mode bool `json:"transform8x8Mode"`
+  ScalingMatrixPresent bool `json:"scalingMatrixPresent"`
+  SecondChromaQpIndexOffset int32 `json:"secondChromaQpIndexOffset"`
+}
+
+func h264HasChromaInfo(profile uint8) bool {
+  switch (profile) {
+  case 100, 110, 122, 244, 44, 83, 86, 118, 128, 138, 139, 134, 135:
+    return true;
+  }
+  return false;
+}
+
+func skipH264ScalingList(br *BitReader, size int) {
+  last := int32(8);
+  next := int32(8);
+
+  for i := 0; i < size; i++ {
+    if (next != 0) {
+      next = (last + br.ReadSE() + 256) % 256;
+    }
+    if (next != 0) {
+      last = next;
+    }
+  }
+}
+
+func skipH264ScalingMatrix(br *BitReader, n int) {
+  for i := 0; i < n; i++ {
+    if (br.ReadFlag()) {
+      if (i < 6) {
+        skipH264ScalingList(br, 16);
+      } else {
+        skipH264ScalingList(br, 64);
+      }
+    }
+  }
+}
+
+func parseH264HRD(br *BitReader) *H264HRD {
+  hrd := H264HRD{};
+
+  hrd.CpbCount = br.ReadUE() + 1;
+
+  if (hrd.CpbCount > 32) {
+    br.err = errors.New("invalid cpb count");
+    return nil;
+  }
+
+  hrd.BitRateScale = uint8(br.ReadBits(4));
+  hrd.CpbSizeScale = uint8(br.ReadBits(4));
+
+  for i := 0; i < int(hrd.CpbCount); i++ {
+    hrd.BitRate = append(hrd.BitRate, br.ReadUE() + 1);
+    hrd.CpbSize = append(hrd.CpbSize, br.ReadUE() + 1);
+    hrd.CBR = append(hrd.CBR, br.ReadFlag());
+  }
+
+  // initial_cpb_removal_delay_length_minus1, cpb_removal_delay_length_minus1,
+  // dpb_output_delay_length_minus1, time_offset_length
+  br.Skip(5 * 4);
+
+  return &hrd;
+}
+
+func parseH264VUI(br *BitReader) *H264VUI {
+  vui := H264VUI{};
+
+  vui.AspectRatioInfoPresent = br.ReadFlag();
+
+  if (vui.AspectRatioInfoPresent) {
+    vui.AspectRatioIdc = uint8(br.ReadBits(8));
+    if (vui.AspectRatioIdc == 255) {
+      vui.SarWidth = uint16(br.ReadBits(16));
+      vui.SarHeight = uint16(br.ReadBits(16));
+    } else if (int(vui.AspectRatioIdc) < len(h264SampleAspectRatios)) {
+      vui.SarWidth = h264SampleAspectRatios[vui.AspectRatioIdc][0];
+      vui.SarHeight = h264SampleAspectRatios[vui.AspectRatioIdc][1];
+    }
+  }
+
+  vui.OverscanInfoPresent = br.ReadFlag();
+
+  if (vui.OverscanInfoPresent) {
+    vui.OverscanAppropriate = br.ReadFlag();
+  }
+
+  vui.VideoSignalTypePresent = br.ReadFlag();
+
+  if (vui.VideoSignalTypePresent) {
+    vui.VideoFormat = uint8(br.ReadBits(3));
+    vui.VideoFullRange = br.ReadFlag();
+    vui.ColourDescriptionPresent = br.ReadFlag();
+    if (vui.ColourDescriptionPresent) {
+      vui.ColourPrimaries = uint8(br.ReadBits(8));
+      vui.TransferCharacteristics = uint8(br.ReadBits(8));
+      vui.MatrixCoefficients = uint8(br.ReadBits(8));
+    }
+  }
+
+  vui.ChromaLocInfoPresent = br.ReadFlag();
+
+  if (vui.ChromaLocInfoPresent) {
+    vui.ChromaSampleLocTop = br.ReadUE();
+    vui.ChromaSampleLocBottom = br.ReadUE();
+  }
+
+  vui.TimingInfoPresent = br.ReadFlag();
+
+  if (vui.TimingInfoPresent) {
+    vui.NumUnitsInTick = uint32(br.ReadBits(32));
+    vui.TimeScale = uint32(br.ReadBits(32));
+    vui.FixedFrameRate = br.ReadFlag();
+  }
+
+  if (br.ReadFlag()) {
+    vui.NalHRD = parseH264HRD(br);
+  }
+
+  if (br.ReadFlag()) {
+    vui.VclHRD = parseH264HRD(br);
+  }
+
+  if (vui.NalHRD != nil || vui.VclHRD != nil) {
+    vui.LowDelayHRD = br.ReadFlag();
+  }
+
+  vui.PicStructPresent = br.ReadFlag();
+  vui.BitstreamRestriction = br.ReadFlag();
+
+  if (vui.BitstreamRestriction) {
+    vui.MotionVectorsOverPicBoundaries = br.ReadFlag();
+    vui.MaxBytesPerPicDenom = br.ReadUE();
+    vui.MaxBitsPerMbDenom = br.ReadUE();
+    vui.Log2MaxMvLengthHorizontal = br.ReadUE();
+    vui.Log2MaxMvLengthVertical = br.ReadUE();
+    vui.MaxNumReorderFrames = br.ReadUE();
+    vui.MaxDecFrameBuffering = br.ReadUE();
+  }
+
+  return &vui;
+}
+
+// ParseH264SPS parses a sequence parameter set NAL unit, header byte
+// included, as stored in avcC.
+func ParseH264SPS(nalu []byte) (*H264SPS, error) {
+  if (len(nalu) < 4 || (nalu[0] & 0x1f) != 7) {
+    return nil, errors.New("not a sequence parameter set");
+  }
+
+  sps := H264SPS{};
+  br := NewBitReader(UnescapeRBSP(nalu[1:]));
+
+  sps.Profile = uint8(br.ReadBits(8));
+  sps.ConstraintFlags = uint8(br.ReadBits(8));
+  sps.Level = uint8(br.ReadBits(8));
+  sps.Id = br.ReadUE();
+
+  sps.ChromaFormat = 1;
+  sps.BitDepthLuma = 8;
+  sps.BitDepthChroma = 8;
+
+  if (h264HasChromaInfo(sps.Profile)) {
+    sps.ChromaFormat = br.ReadUE();
+    if (sps.ChromaFormat == 3) {
+      sps.SeparateColourPlane = br.ReadFlag();
+    }
+    sps.BitDepthLuma = br.ReadUE() + 8;
+    sps.BitDepthChroma = br.ReadUE() + 8;
+    sps.QpprimeYZeroTransformBypass = br.ReadFlag();
+    sps.ScalingMatrixPresent = br.ReadFlag();
+    if (sps.ScalingMatrixPresent) {
+      if (sps.ChromaFormat != 3) {
+        skipH264ScalingMatrix(br, 8);
+      } else {
+        skipH264ScalingMatrix(br, 12);
+      }
+    }
+  }
+
+  sps.Log2MaxFrameNum = br.ReadUE() + 4;
+  sps.PicOrderCntType = br.ReadUE();
+
+  if (sps.PicOrderCntType == 0) {
+    sps.Log2MaxPicOrderCntLsb = br.ReadUE() + 4;
+  } else if (sps.PicOrderCntType == 1) {
+    sps.DeltaPicOrderAlwaysZero = br.ReadFlag();
+    sps.OffsetForNonRefPic = br.ReadSE();
+    sps.OffsetForTopToBottomField = br.ReadSE();
+    n := br.ReadUE();
+    if (n > 255) {
+      return nil, errors.New("invalid num_ref_frames_in_pic_order_cnt_cycle");
+    }
+    for i := 0; i < int(n); i++ {
+      sps.OffsetForRefFrame = append(sps.OffsetForRefFrame, br.ReadSE());
+    }
+  }
+
+  sps.MaxNumRefFrames = br.ReadUE();
+  sps.GapsInFrameNumAllowed = br.ReadFlag();
+  sps.PicWidthInMbs = br.ReadUE() + 1;
+  sps.PicHeightInMapUnits = br.ReadUE() + 1;
+  sps.FrameMbsOnly = br.ReadFlag();
+
+  if (!sps.FrameMbsOnly) {
+    sps.MbAdaptiveFrameField = br.ReadFlag();
+  }
+
+  sps.Direct8x8Inference = br.ReadFlag();
+  sps.FrameCropping = br.ReadFlag();
+
+  if (sps.FrameCropping) {
+    sps.CropLeft = br.ReadUE();
+    sps.CropRight = br.ReadUE();
+    sps.CropTop = br.ReadUE();
+    sps.CropBottom = br.ReadUE();
+  }
+
+  if (br.ReadFlag()) {
+    sps.VUI = parseH264VUI(br);
+  }
+
+  if (br.Err() != nil) {
+    return nil, br.Err();
+  }
+
+  fieldFactor := uint32(2);
+
+  if (sps.FrameMbsOnly) {
+    fieldFactor = 1;
+  }
+
+  sps.CodedWidth = sps.PicWidthInMbs * 16;
+  sps.CodedHeight = sps.PicHeightInMapUnits * 16 * fieldFactor;
+
+  cropX := uint32(1);
+  cropY := fieldFactor;
+
+  if (!sps.SeparateColourPlane) {
+    switch (sps.ChromaFormat) {
+    case 1:
+      cropX = 2;
+      cropY = 2 * fieldFactor;
+    case 2:
+      cropX = 2;
+    }
+  }
+
+  sps.Width = sps.CodedWidth - cropX * (sps.CropLeft + sps.CropRight);
+  sps.Height = sps.CodedHeight - cropY * (sps.CropTop + sps.CropBottom);
+
+  return &sps, nil;
+}
+
+// h264PPSSPSId peeks at the SPS id of a picture parameter set, its second
+// ue(v), to find the SPS needed to parse it.
+func h264PPSSPSId(nalu []byte) uint32 {
+  if (len(nalu) < 2) {
+    return 0;
+  }
+
+  br := NewBitReader(UnescapeRBSP(nalu[1:]));
+  br.ReadUE();
+
+  return br.ReadUE();
+}
+
+// ParseH264PPS parses a picture parameter set NAL unit. The matching SPS is
+// needed to size the scaling matrices of 4:4:4 streams and can be nil
+// otherwise.
+func ParseH264PPS(nalu []byte, sps *H264SPS) (*H264PPS, error) {
+  if (len(nalu) < 2 || (nalu[0] & 0x1f) != 8) {
+    return nil, errors.New("not a picture parameter set");
+  }
+
+  pps := H264PPS{};
+  br := NewBitReader(UnescapeRBSP(nalu[1:]));
+
+  pps.Id = br.ReadUE();
+  pps.SPSId = br.ReadUE();
+  pps.EntropyCodingMode = br.ReadFlag();
+  pps.BottomFieldPicOrderInFramePresent = br.ReadFlag();
+  pps.NumSliceGroups = br.ReadUE() + 1;
+
+  if (pps.NumSliceGroups > 8) {
+    return nil, errors.New("invalid num_slice_groups");
+  }
+
+  if (pps.NumSliceGroups > 1) {
+    pps.SliceGroupMapType = br.ReadUE();
+    switch (pps.SliceGroupMapType) {
+    case 0:
+      for i := 0; i < int(pps.NumSliceGroups); i++ {
+        br.ReadUE();
+      }
+    case 2:
+      for i := 0; i < int(pps.NumSliceGroups) - 1; i++ {
+        br.ReadUE();
+        br.ReadUE();
+      }
+    case 3, 4, 5:
+      br.Skip(1);
+      pps.SliceGroupChangeRate = br.ReadUE() + 1;
+    case 6:
+      n := br.ReadUE() + 1;
+      bits := 0;
+      for (1 << uint(bits)) < pps.NumSliceGroups {
+        bits++;
+      }
+      br.Skip(int(n) * bits);
+    }
+  }
+
+  pps.NumRefIdxL0DefaultActive = br.ReadUE() + 1;
+  pps.NumRefIdxL1DefaultActive = br.ReadUE() + 1;
+  pps.WeightedPred = br.ReadFlag();
+  pps.WeightedBipredIdc = uint8(br.ReadBits(2));
+  pps.PicInitQp = br.ReadSE() + 26;
+  pps.PicInitQs = br.ReadSE() + 26;
+  pps.ChromaQpIndexOffset = br.ReadSE();
+  pps.DeblockingFilterControlPresent = br.ReadFlag();
+  pps.ConstrainedIntraPred = br.ReadFlag();
+  pps.RedundantPicCntPresent = br.ReadFlag();
+  pps.SecondChromaQpIndexOffset = pps.ChromaQpIndexOffset;
+
+  if (br.Err() == nil && br.MoreRBSPData()) {
+    pps.Transform8x8Mode = br.ReadFlag();
+    pps.ScalingMatrixPresent = br.ReadFlag();
+    if (pps.ScalingMatrixPresent) {
+      n := 6;
+      if (pps.Transform8x8Mode) {
+        if (sps != nil && sps.ChromaFormat == 3) {
+          n += 6;
+        } else {
+          n += 2;
+        }
+      }
+      skipH264ScalingMatrix(br, n);
+    }
+    pps.SecondChromaQpIndexOffset = br.ReadSE();
+  }
+
+  if (br.Err() != nil) {
+    return nil, br.Err();
+  }
+
+  return &pps, nil;
+}
+
+// DecodeSPS parses every SPS of the record.
+func (avcc AVCcBox) DecodeSPS() ([]*H264SPS, error) {
+  var res []*H264SPS;
+
+  for _,nalu := range avcc.SPS {
+    sps,err := ParseH264SPS(nalu);
+    if (err != nil) {
+      return nil, err;
+    }
+    res = append(res, sps);
+  }
+
+  return res, nil;
+}
+
+// DecodePPS parses every PPS of the record, matching each one to its SPS.
+func (avcc AVCcBox) DecodePPS() ([]*H264PPS, error) {
+  spss,err := avcc.DecodeSPS();
+
+  if (err != nil) {
+    return nil, err;
+  }
+
+  var res []*H264PPS;
+
+  for _,nalu := range avcc.PPS {
+    var sps *H264SPS;
+
+    id := h264PPSSPSId(nalu);
+
+    for _,s := range spss {
+      if (s.Id == id) {
+        sps = s;
+      }
+    }
+
+    pps,err := ParseH264PPS(nalu, sps);
+
+    if (err != nil) {
+      return nil, err;
+    }
+
+    res = append(res, pps);
+  }
+
+  return res, nil;
+}
+
+func skipH264RefPicListModification(br *BitReader) {
+  if (!br.ReadFlag()) {
+    return;
+  }
+
+  // modification_of_pic_nums_idc, 3 ends the list
+  for br.Err() == nil {
+    idc := br.ReadUE();
+    if (idc == 3) {
+      return;
+    }
+    br.ReadUE();
+  }
+}
+
+func skipH264PredWeightTable(br *BitReader, chroma bool, numRefIdx []uint32) {
+  br.ReadUE();
+
+  if (chroma) {
+    br.ReadUE();
+  }
+
+  for _,n := range numRefIdx {
+    for i := 0; i < int(n); i++ {
+      if (br.ReadFlag()) {
+        br.ReadSE();
+        br.ReadSE();
+      }
+      if (chroma && br.ReadFlag()) {
+        for j := 0; j < 4; j++ {
+          br.ReadSE();
+        }
+      }
+    }
+  }
+}
+
+func skipH264DecRefPicMarking(br *BitReader, idr bool) {
+  if (idr) {
+    // no_output_of_prior_pics_flag, long_term_reference_flag
+    br.Skip(2);
+    return;
+  }
+
+  if (!br.ReadFlag()) {
+    return;
+  }
+
+  for br.Err() == nil {
+    switch (br.ReadUE()) {
+    case 0:
+      return;
+    case 3:
+      br.ReadUE();
+      br.ReadUE();
+    case 1, 2, 4, 6:
+      br.ReadUE();
+    }
+  }
+}
+
+// h264SliceHeaderSize returns the size in bytes of the NAL unit header and
+// slice header of a coded slice NAL unit, emulation prevention bytes
+// included. The parameter sets are looked up by id.
+func h264SliceHeaderSize(nalu []byte, spss map[uint32]*H264SPS, ppss map[uint32]*H264PPS) (int, error) {
+  if (len(nalu) < 2) {
+    return 0, errors.New("truncated slice");
+  }
+
+  typ := nalu[0] & 0x1f;
+  refIdc := (nalu[0] >> 5) & 0x03;
+  br := NewBitReader(UnescapeRBSP(nalu[1:]));
+
+  br.ReadUE();
+  sliceType := br.ReadUE() % 5;
+  ppsId := br.ReadUE();
+
+  if (br.Err() != nil) {
+    return 0, br.Err();
+  }
+
+  pps := ppss[ppsId];
+
+  if (pps == nil) {
+    return 0, fmt.Errorf("unknown picture parameter set %d", ppsId);
+  }
+
+  sps := spss[pps.SPSId];
+
+  if (sps == nil) {
+    return 0, fmt.Errorf("unknown sequence parameter set %d", pps.SPSId);
+  }
+
+  p := sliceType == 0 || sliceType == 3;
+  b := sliceType == 1;
+  intra := sliceType == 2 || sliceType == 4;
+
+  if (sps.SeparateColourPlane) {
+    br.Skip(2);
+  }
+
+  br.Skip(int(sps.Log2MaxFrameNum));
+
+  field := false;
+
+  if (!sps.FrameMbsOnly) {
+    field = br.ReadFlag();
+    if (field) {
+      br.Skip(1);
+    }
+  }
+
+  if (typ == 5) {
+    br.ReadUE();
+  }
+
+  if (sps.PicOrderCntType == 0) {
+    br.Skip(int(sps.Log2MaxPicOrderCntLsb));
+    if (pps.BottomFieldPicOrderInFramePresent && !field) {
+      br.ReadSE();
+    }
+  } else if (sps.PicOrderCntType == 1 && !sps.DeltaPicOrderAlwaysZero) {
+    br.ReadSE();
+    if (pps.BottomFieldPicOrderInFramePresent && !field) {
+      br.ReadSE();
+    }
+  }
+
+  if (pps.RedundantPicCntPresent) {
+    br.ReadUE();
+  }
+
+  if (b) {
+    // direct_spatial_mv_pred_flag
+    br.Skip(1);
+  }
+
+  numRefIdx := []uint32{pps.NumRefIdxL0DefaultActive, pps.NumRefIdxL1DefaultActive};
+
+  if (p || b) {
+    if (br.ReadFlag()) {
+      numRefIdx[0] = br.ReadUE() + 1;
+      if (b) {
+        numRefIdx[1] = br.ReadUE() + 1;
+      }
+    }
+  }
+
+  if (!b) {
+    numRefIdx = numRefIdx[:1];
+  }
+
+  if (!intra) {
+    skipH264RefPicListModification(br);
+    if (b) {
+      skipH264RefPicListModification(br);
+    }
+  }
+
+  if ((pps.WeightedPred && p) || (pps.WeightedBipredIdc == 1 && b)) {
+    // ChromaArrayType is zero for monochrome and separate colour planes
+    chroma := sps.ChromaFormat != 0 && !sps.SeparateColourPlane;
+    skipH264PredWeightTable(br, chroma, numRefIdx);
+  }
+
+  if (refIdc != 0) {
+    skipH264DecRefPicMarking(br, typ == 5);
+  }
+
+  if (pps.EntropyCodingMode && !intra) {
+    br.ReadUE();
+  }
+
+  br.ReadSE();
+
+  if (sliceType == 3 || sliceType == 4) {
+    if (sliceType == 3) {
+      br.Skip(1);
+    }
+    br.ReadSE();
+  }
+
+  if (pps.DeblockingFilterControlPresent && br.ReadUE() != 1) {
+    br.ReadSE();
+    br.ReadSE();
+  }
+
+  if (pps.NumSliceGroups > 1 && pps.SliceGroupMapType >= 3 && pps.SliceGroupMapType <= 5 && pps.SliceGroupChangeRate > 0) {
+    // Ceil(Log2(PicSizeInMapUnits / SliceGroupChangeRate + 1))
+    size := uint64(sps.PicWidthInMbs) * uint64(sps.PicHeightInMapUnits);
+    rate := uint64(pps.SliceGroupChangeRate);
+    bits := 0;
+    for (rate << uint(bits)) < size + rate {
+      bits++;
+    }
+    br.Skip(bits);
+  }
+
+  if (br.Err() != nil) {
+    return 0, br.Err();
+  }
+
+  return 1 + escapedSize(nalu[1:], (br.pos + 7) / 8), nil;
+}
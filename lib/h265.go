@@ -0,0 +1,856 @@
+package mp4
+
+import (
+  "fmt"
+  "errors"
+)
+
+// H265VUI holds the leading VUI fields, up to the colour description.
+type H265VUI struct {
+  AspectRatioInfoPresent bool `json:"aspectRatioInfoPresent"`
+  AspectRatioIdc uint8 `json:"aspectRatioIdc"`
+  SarWidth uint16 `json:"sarWidth"`
+  SarHeight uint16 `json:"sarHeight"`
+  OverscanInfoPresent bool `json:"overscanInfoPresent"`
+  OverscanAppropriate bool `json:"overscanAppropriate"`
+  VideoSignalTypePresent bool `json:"videoSignalTypePresent"`
+  VideoFormat uint8 `json:"videoFormat"`
+  VideoFullRange bool `json:"videoFullRange"`
+  ColourDescriptionPresent bool `json:"colourDescriptionPresent"`
+  ColourPrimaries uint8 `json:"colourPrimaries"`
+  TransferCharacteristics uint8 `json:"transferCharacteristics"`
+  MatrixCoefficients uint8 `json:"matrixCoefficients"`
+}
+
+// h265RefPicSet is a short term reference picture set, st_ref_pic_set()
+// in the H.265 specification, with the POC deltas derived.
+type h265RefPicSet struct {
+  deltaPocS0 []int32
+  usedS0 []bool
+  deltaPocS1 []int32
+  usedS1 []bool
+}
+
+func (rps *h265RefPicSet) numDeltaPocs() int {
+  return len(rps.deltaPocS0) + len(rps.deltaPocS1);
+}
+
+func (rps *h265RefPicSet) numUsed() int {
+  n := 0;
+  for _,used := range rps.usedS0 {
+    if (used) {
+      n++;
+    }
+  }
+  for _,used := range rps.usedS1 {
+    if (used) {
+      n++;
+    }
+  }
+  return n;
+}
+
+type H265SPS struct {
+  VPSId uint8 `json:"vpsId"`
+  MaxSubLayers uint8 `json:"maxSubLayers"`
+  TemporalIdNesting bool `json:"temporalIdNesting"`
+  ProfileSpace uint8 `json:"profileSpace"`
+  TierFlag bool `json:"tierFlag"`
+  ProfileIdc uint8 `json:"profileIdc"`
+  Level uint8 `json:"level"`
+  Id uint32 `json:"id"`
+  ChromaFormat uint32 `json:"chromaFormat"`
+  SeparateColourPlane bool `json:"separateColourPlane"`
+  Width uint32 `json:"width"`
+  Height uint32 `json:"height"`
+  ConformanceWindow [4]uint32 `json:"conformanceWindow"`
+  BitDepthLuma uint32 `json:"bitDepthLuma"`
+  BitDepthChroma uint32 `json:"bitDepthChroma"`
+  Log2MaxPicOrderCntLsb uint32 `json:"log2MaxPicOrderCntLsb"`
+  Log2MinLumaCodingBlockSize uint32 `json:"log2MinLumaCodingBlockSize"`
+  Log2CtbSize uint32 `json:"log2CtbSize"`
+  ScalingListEnabled bool `json:"scalingListEnabled"`
+  AmpEnabled bool `json:"ampEnabled"`
+  SampleAdaptiveOffsetEnabled bool `json:"sampleAdaptiveOffsetEnabled"`
+  PCMEnabled bool `json:"pcmEnabled"`
+  NumShortTermRefPicSets uint32 `json:"numShortTermRefPicSets"`
+  LongTermRefPicsPresent bool `json:"longTermRefPicsPresent"`
+  NumLongTermRefPicsSps uint32 `json:"numLongTermRefPicsSps"`
+  TemporalMvpEnabled bool `json:"temporalMvpEnabled"`
+  StrongIntraSmoothingEnabled bool `json:"strongIntraSmoothingEnabled"`
+  VUI *H265VUI `json:"vui"`
+  stRefPicSets []h265RefPicSet
+  // used_by_curr_pic_lt_sps_flag of the long term candidates
+  usedByCurrPicLt []bool
+}
+
+type H265PPS struct {
+  Id uint32 `json:"id"`
+  SPSId uint32 `json:"spsId"`
+  DependentSliceSegmentsEnabled bool `json:"dependentSliceSegmentsEnabled"`
+  OutputFlagPresent bool `json:"outputFlagPresent"`
+  NumExtraSliceHeaderBits uint8 `json:"numExtraSliceHeaderBits"`
+  SignDataHidingEnabled bool `json:"signDataHidingEnabled"`
+  CabacInitPresent bool `json:"cabacInitPresent"`
+  NumRefIdxL0DefaultActive uint32 `json:"numRefIdxL0DefaultActive"`
+  NumRefIdxL1DefaultActive uint32 `json:"numRefIdxL1DefaultActive"`
+  InitQp int32 `json:"initQp"`
+  ConstrainedIntraPred bool `json:"constrainedIntraPred"`
+  TransformSkipEnabled bool `json:"transformSkipEnabled"`
+  CuQpDeltaEnabled bool `json:"cuQpDeltaEnabled"`
+  CbQpOffset int32 `json:"cbQpOffset"`
+  CrQpOffset int32 `json:"crQpOffset"`
+  SliceChromaQpOffsetsPresent bool `json:"sliceChromaQpOffsetsPresent"`
+  WeightedPred bool `json:"weightedPred"`
+  WeightedBipred bool `json:"weightedBipred"`
+  TransquantBypassEnabled bool `json:"transquantBypassEnabled"`
+  TilesEnabled bool `json:"tilesEnabled"`
+  EntropyCodingSyncEnabled bool `json:"entropyCodingSyncEnabled"`
+  NumTileColumns uint32 `json:"numTileColumns"`
+  NumTileRows uint32 `json:"numTileRows"`
+  LoopFilterAcrossSlicesEnabled bool `json:"loopFilterAcrossSlicesEnabled"`
+  DeblockingFilterOverrideEnabled bool `json:"deblockingFilterOverrideEnabled"`
+  DeblockingFilterDisabled bool `json:"deblockingFilterDisabled"`
+  ListsModificationPresent bool `json:"listsModificationPresent"`
+  Log2ParallelMergeLevel uint32 `json:"log2ParallelMergeLevel"`
+  SliceSegmentHeaderExtensionPresent bool `json:"sliceSegmentHeaderExtensionPresent"`
+  ChromaQpOffsetListEnabled bool `json:"chromaQpOffsetListEnabled"`
+}
+
+// parseH265ProfileTierLevel reads profile_tier_level(), keeping only the
+// general profile space, tier, profile and level.
+func parseH265ProfileTierLevel(br *BitReader, sps *H265SPS) {
+  sps.ProfileSpace = uint8(br.ReadBits(2));
+  sps.TierFlag = br.ReadFlag();
+  sps.ProfileIdc = uint8(br.ReadBits(5));
+  // compatibility flags, source and constraint flags
+  br.Skip(32 + 4 + 43 + 1);
+  sps.Level = uint8(br.ReadBits(8));
+
+  subLayers := int(sps.MaxSubLayers) - 1;
+  profilePresent := make([]bool, subLayers);
+  levelPresent := make([]bool, subLayers);
+
+  for i := 0; i < subLayers; i++ {
+    profilePresent[i] = br.ReadFlag();
+    levelPresent[i] = br.ReadFlag();
+  }
+
+  if (subLayers > 0) {
+    br.Skip(2 * (8 - subLayers));
+  }
+
+  for i := 0; i < subLayers; i++ {
+    if (profilePresent[i]) {
+      br.Skip(88);
+    }
+    if (levelPresent[i]) {
+      br.Skip(8);
+    }
+  }
+}
+
+func skipH265ScalingListData(br *BitReader) {
+  for sizeId := 0; sizeId < 4; sizeId++ {
+    step := 1;
+    if (sizeId == 3) {
+      step = 3;
+    }
+    for matrixId := 0; matrixId < 6; matrixId += step {
+      if (!br.ReadFlag()) {
+        br.ReadUE();
+        continue;
+      }
+      coefs := 64;
+      if (sizeId == 0) {
+        coefs = 16;
+      }
+      if (sizeId > 1) {
+        br.ReadSE();
+      }
+      for i := 0; i < coefs; i++ {
+        br.ReadSE();
+      }
+    }
+  }
+}
+
+// parseH265RefPicSet reads st_ref_pic_set(idx), sets holding the ones
+// parsed before it. Slice headers pass the number of sets of the SPS, num,
+// as idx.
+func parseH265RefPicSet(br *BitReader, idx int, num int, sets []h265RefPicSet) (h265RefPicSet, error) {
+  rps := h265RefPicSet{};
+
+  if (idx != 0 && br.ReadFlag()) {
+    // inter_ref_pic_set_prediction_flag, the set is predicted from an
+    // earlier one
+    deltaIdx := 1;
+    if (idx == num) {
+      deltaIdx = int(br.ReadUE()) + 1;
+    }
+    if (deltaIdx > idx) {
+      return rps, errors.New("invalid delta_idx");
+    }
+    sign := br.ReadFlag();
+    deltaRps := int32(br.ReadUE()) + 1;
+    if (sign) {
+      deltaRps = -deltaRps;
+    }
+
+    ref := &sets[idx - deltaIdx];
+    n := ref.numDeltaPocs();
+    used := make([]bool, n + 1);
+    useDelta := make([]bool, n + 1);
+
+    for j := 0; j <= n; j++ {
+      used[j] = br.ReadFlag();
+      useDelta[j] = true;
+      if (!used[j]) {
+        useDelta[j] = br.ReadFlag();
+      }
+    }
+
+    // equations 7-61 and 7-62, flags index S0 then S1 then the
+    // reference picture itself
+    neg := len(ref.deltaPocS0);
+
+    for j := len(ref.deltaPocS1) - 1; j >= 0; j-- {
+      d := ref.deltaPocS1[j] + deltaRps;
+      if (d < 0 && useDelta[neg + j]) {
+        rps.deltaPocS0 = append(rps.deltaPocS0, d);
+        rps.usedS0 = append(rps.usedS0, used[neg + j]);
+      }
+    }
+    if (deltaRps < 0 && useDelta[n]) {
+      rps.deltaPocS0 = append(rps.deltaPocS0, deltaRps);
+      rps.usedS0 = append(rps.usedS0, used[n]);
+    }
+    for j := 0; j < neg; j++ {
+      d := ref.deltaPocS0[j] + deltaRps;
+      if (d < 0 && useDelta[j]) {
+        rps.deltaPocS0 = append(rps.deltaPocS0, d);
+        rps.usedS0 = append(rps.usedS0, used[j]);
+      }
+    }
+
+    for j := neg - 1; j >= 0; j-- {
+      d := ref.deltaPocS0[j] + deltaRps;
+      if (d > 0 && useDelta[j]) {
+        rps.deltaPocS1 = append(rps.deltaPocS1, d);
+        rps.usedS1 = append(rps.usedS1, used[j]);
+      }
+    }
+    if (deltaRps > 0 && useDelta[n]) {
+      rps.deltaPocS1 = append(rps.deltaPocS1, deltaRps);
+      rps.usedS1 = append(rps.usedS1, used[n]);
+    }
+    for j := 0; j < len(ref.deltaPocS1); j++ {
+      d := ref.deltaPocS1[j] + deltaRps;
+      if (d > 0 && useDelta[neg + j]) {
+        rps.deltaPocS1 = append(rps.deltaPocS1, d);
+        rps.usedS1 = append(rps.usedS1, used[neg + j]);
+      }
+    }
+
+    return rps, br.Err();
+  }
+
+  neg := br.ReadUE();
+  pos := br.ReadUE();
+
+  if (neg > 16 || pos > 16) {
+    return rps, errors.New("invalid short term reference picture set");
+  }
+
+  poc := int32(0);
+
+  for i := 0; i < int(neg); i++ {
+    poc -= int32(br.ReadUE()) + 1;
+    rps.deltaPocS0 = append(rps.deltaPocS0, poc);
+    rps.usedS0 = append(rps.usedS0, br.ReadFlag());
+  }
+
+  poc = 0;
+
+  for i := 0; i < int(pos); i++ {
+    poc += int32(br.ReadUE()) + 1;
+    rps.deltaPocS1 = append(rps.deltaPocS1, poc);
+    rps.usedS1 = append(rps.usedS1, br.ReadFlag());
+  }
+
+  return rps, br.Err();
+}
+
+// parseH265VUI reads the VUI up to the colour description, which is all
+// that is needed from it.
+func parseH265VUI(br *BitReader) *H265VUI {
+  vui := H265VUI{};
+
+  vui.AspectRatioInfoPresent = br.ReadFlag();
+
+  if (vui.AspectRatioInfoPresent) {
+    vui.AspectRatioIdc = uint8(br.ReadBits(8));
+    if (vui.AspectRatioIdc == 255) {
+      vui.SarWidth = uint16(br.ReadBits(16));
+      vui.SarHeight = uint16(br.ReadBits(16));
+    } else if (int(vui.AspectRatioIdc) < len(h264SampleAspectRatios)) {
+      vui.SarWidth = h264SampleAspectRatios[vui.AspectRatioIdc][0];
+      vui.SarHeight = h264SampleAspectRatios[vui.AspectRatioIdc][1];
+    }
+  }
+
+  vui.OverscanInfoPresent = br.ReadFlag();
+
+  if (vui.OverscanInfoPresent) {
+    vui.OverscanAppropriate = br.ReadFlag();
+  }
+
+  vui.VideoSignalTypePresent = br.ReadFlag();
+
+  if (vui.VideoSignalTypePresent) {
+    vui.VideoFormat = uint8(br.ReadBits(3));
+    vui.VideoFullRange = br.ReadFlag();
+    vui.ColourDescriptionPresent = br.ReadFlag();
+    if (vui.ColourDescriptionPresent) {
+      vui.ColourPrimaries = uint8(br.ReadBits(8));
+      vui.TransferCharacteristics = uint8(br.ReadBits(8));
+      vui.MatrixCoefficients = uint8(br.ReadBits(8));
+    }
+  }
+
+  return &vui;
+}
+
+// ParseH265SPS parses a sequence parameter set NAL unit, the two byte NAL
+// unit header included, as stored in hvcC.
+func ParseH265SPS(nalu []byte) (*H265SPS, error) {
+  if (len(nalu) < 3 || ((nalu[0] >> 1) & 0x3f) != 33) {
+    return nil, errors.New("not a sequence parameter set");
+  }
+
+  sps := H265SPS{};
+  br := NewBitReader(UnescapeRBSP(nalu[2:]));
+
+  sps.VPSId = uint8(br.ReadBits(4));
+  sps.MaxSubLayers = uint8(br.ReadBits(3)) + 1;
+  sps.TemporalIdNesting = br.ReadFlag();
+
+  if (sps.MaxSubLayers > 7) {
+    return nil, errors.New("invalid sps_max_sub_layers_minus1");
+  }
+
+  parseH265ProfileTierLevel(br, &sps);
+
+  sps.Id = br.ReadUE();
+  sps.ChromaFormat = br.ReadUE();
+
+  if (sps.ChromaFormat == 3) {
+    sps.SeparateColourPlane = br.ReadFlag();
+  }
+
+  sps.Width = br.ReadUE();
+  sps.Height = br.ReadUE();
+
+  if (br.ReadFlag()) {
+    for i := range sps.ConformanceWindow {
+      sps.ConformanceWindow[i] = br.ReadUE();
+    }
+  }
+
+  sps.BitDepthLuma = br.ReadUE() + 8;
+  sps.BitDepthChroma = br.ReadUE() + 8;
+  sps.Log2MaxPicOrderCntLsb = br.ReadUE() + 4;
+
+  if (sps.Log2MaxPicOrderCntLsb > 16) {
+    return nil, errors.New("invalid log2_max_pic_order_cnt_lsb_minus4");
+  }
+
+  // sps_max_dec_pic_buffering, sps_max_num_reorder_pics and
+  // sps_max_latency_increase, for every sub layer or the highest one only
+  first := int(sps.MaxSubLayers) - 1;
+
+  if (br.ReadFlag()) {
+    first = 0;
+  }
+
+  for i := first; i < int(sps.MaxSubLayers); i++ {
+    br.ReadUE();
+    br.ReadUE();
+    br.ReadUE();
+  }
+
+  sps.Log2MinLumaCodingBlockSize = br.ReadUE() + 3;
+  sps.Log2CtbSize = sps.Log2MinLumaCodingBlockSize + br.ReadUE();
+
+  if (sps.Log2CtbSize > 6) {
+    return nil, errors.New("invalid coding tree block size");
+  }
+
+  // transform block sizes and hierarchy depths
+  br.ReadUE();
+  br.ReadUE();
+  br.ReadUE();
+  br.ReadUE();
+
+  sps.ScalingListEnabled = br.ReadFlag();
+
+  if (sps.ScalingListEnabled && br.ReadFlag()) {
+    skipH265ScalingListData(br);
+  }
+
+  sps.AmpEnabled = br.ReadFlag();
+  sps.SampleAdaptiveOffsetEnabled = br.ReadFlag();
+  sps.PCMEnabled = br.ReadFlag();
+
+  if (sps.PCMEnabled) {
+    br.Skip(8);
+    br.ReadUE();
+    br.ReadUE();
+    br.Skip(1);
+  }
+
+  sps.NumShortTermRefPicSets = br.ReadUE();
+
+  if (sps.NumShortTermRefPicSets > 64) {
+    return nil, errors.New("invalid num_short_term_ref_pic_sets");
+  }
+
+  for i := 0; i < int(sps.NumShortTermRefPicSets); i++ {
+    rps,err := parseH265RefPicSet(br, i, int(sps.NumShortTermRefPicSets), sps.stRefPicSets);
+    if (err != nil) {
+      return nil, err;
+    }
+    sps.stRefPicSets = append(sps.stRefPicSets, rps);
+  }
+
+  sps.LongTermRefPicsPresent = br.ReadFlag();
+
+  if (sps.LongTermRefPicsPresent) {
+    sps.NumLongTermRefPicsSps = br.ReadUE();
+    if (sps.NumLongTermRefPicsSps > 32) {
+      return nil, errors.New("invalid num_long_term_ref_pics_sps");
+    }
+    for i := 0; i < int(sps.NumLongTermRefPicsSps); i++ {
+      br.Skip(int(sps.Log2MaxPicOrderCntLsb));
+      sps.usedByCurrPicLt = append(sps.usedByCurrPicLt, br.ReadFlag());
+    }
+  }
+
+  sps.TemporalMvpEnabled = br.ReadFlag();
+  sps.StrongIntraSmoothingEnabled = br.ReadFlag();
+
+  if (br.ReadFlag()) {
+    sps.VUI = parseH265VUI(br);
+  }
+
+  if (br.Err() != nil) {
+    return nil, br.Err();
+  }
+
+  return &sps, nil;
+}
+
+// DecodeSPS parses every SPS found in the NAL unit arrays of the record.
+func (hvcc HVCcBox) DecodeSPS() ([]*H265SPS, error) {
+  var res []*H265SPS;
+
+  for _,nalu := range hvcc.SPS {
+    sps,err := ParseH265SPS(nalu);
+    if (err != nil) {
+      return nil, err;
+    }
+    res = append(res, sps);
+  }
+
+  return res, nil;
+}
+
+// ParseH265PPS parses a picture parameter set NAL unit, the two byte NAL
+// unit header included, up to the range extension.
+func ParseH265PPS(nalu []byte) (*H265PPS, error) {
+  if (len(nalu) < 3 || ((nalu[0] >> 1) & 0x3f) != 34) {
+    return nil, errors.New("not a picture parameter set");
+  }
+
+  pps := H265PPS{};
+  br := NewBitReader(UnescapeRBSP(nalu[2:]));
+
+  pps.Id = br.ReadUE();
+  pps.SPSId = br.ReadUE();
+  pps.DependentSliceSegmentsEnabled = br.ReadFlag();
+  pps.OutputFlagPresent = br.ReadFlag();
+  pps.NumExtraSliceHeaderBits = uint8(br.ReadBits(3));
+  pps.SignDataHidingEnabled = br.ReadFlag();
+  pps.CabacInitPresent = br.ReadFlag();
+  pps.NumRefIdxL0DefaultActive = br.ReadUE() + 1;
+  pps.NumRefIdxL1DefaultActive = br.ReadUE() + 1;
+  pps.InitQp = br.ReadSE() + 26;
+  pps.ConstrainedIntraPred = br.ReadFlag();
+  pps.TransformSkipEnabled = br.ReadFlag();
+  pps.CuQpDeltaEnabled = br.ReadFlag();
+
+  if (pps.CuQpDeltaEnabled) {
+    br.ReadUE();
+  }
+
+  pps.CbQpOffset = br.ReadSE();
+  pps.CrQpOffset = br.ReadSE();
+  pps.SliceChromaQpOffsetsPresent = br.ReadFlag();
+  pps.WeightedPred = br.ReadFlag();
+  pps.WeightedBipred = br.ReadFlag();
+  pps.TransquantBypassEnabled = br.ReadFlag();
+  pps.TilesEnabled = br.ReadFlag();
+  pps.EntropyCodingSyncEnabled = br.ReadFlag();
+  pps.NumTileColumns = 1;
+  pps.NumTileRows = 1;
+
+  if (pps.TilesEnabled) {
+    pps.NumTileColumns = br.ReadUE() + 1;
+    pps.NumTileRows = br.ReadUE() + 1;
+    if (pps.NumTileColumns > 20 || pps.NumTileRows > 22) {
+      return nil, errors.New("invalid tile count");
+    }
+    if (!br.ReadFlag()) {
+      // column widths and row heights
+      for i := 0; i < int(pps.NumTileColumns + pps.NumTileRows) - 2; i++ {
+        br.ReadUE();
+      }
+    }
+    // loop_filter_across_tiles_enabled_flag
+    br.Skip(1);
+  }
+
+  pps.LoopFilterAcrossSlicesEnabled = br.ReadFlag();
+
+  if (br.ReadFlag()) {
+    pps.DeblockingFilterOverrideEnabled = br.ReadFlag();
+    pps.DeblockingFilterDisabled = br.ReadFlag();
+    if (!pps.DeblockingFilterDisabled) {
+      br.ReadSE();
+      br.ReadSE();
+    }
+  }
+
+  if (br.ReadFlag()) {
+    skipH265ScalingListData(br);
+  }
+
+  pps.ListsModificationPresent = br.ReadFlag();
+  pps.Log2ParallelMergeLevel = br.ReadUE() + 2;
+  pps.SliceSegmentHeaderExtensionPresent = br.ReadFlag();
+
+  // only the range extension changes the slice header syntax of single
+  // layer streams
+  if (br.ReadFlag() && br.ReadFlag()) {
+    br.Skip(7);
+    if (pps.TransformSkipEnabled) {
+      br.ReadUE();
+    }
+    // cross_component_prediction_enabled_flag
+    br.Skip(1);
+    pps.ChromaQpOffsetListEnabled = br.ReadFlag();
+  }
+
+  if (br.Err() != nil) {
+    return nil, br.Err();
+  }
+
+  return &pps, nil;
+}
+
+// DecodePPS parses every PPS found in the NAL unit arrays of the record.
+func (hvcc HVCcBox) DecodePPS() ([]*H265PPS, error) {
+  var res []*H265PPS;
+
+  for _,nalu := range hvcc.PPS {
+    pps,err := ParseH265PPS(nalu);
+    if (err != nil) {
+      return nil, err;
+    }
+    res = append(res, pps);
+  }
+
+  return res, nil;
+}
+
+func skipH265PredWeightTable(br *BitReader, chroma bool, numRefIdx []uint32) {
+  br.ReadUE();
+
+  if (chroma) {
+    br.ReadSE();
+  }
+
+  // the weight flags are present for every reference picture as none of
+  // them shares the POC of the current one in single layer streams
+  for _,n := range numRefIdx {
+    luma := make([]bool, n);
+    chromaWeights := make([]bool, n);
+
+    for i := range luma {
+      luma[i] = br.ReadFlag();
+    }
+
+    if (chroma) {
+      for i := range chromaWeights {
+        chromaWeights[i] = br.ReadFlag();
+      }
+    }
+
+    for i := range luma {
+      if (luma[i]) {
+        br.ReadSE();
+        br.ReadSE();
+      }
+      if (chromaWeights[i]) {
+        for j := 0; j < 4; j++ {
+          br.ReadSE();
+        }
+      }
+    }
+  }
+}
+
+// h265SliceHeaderSize returns the size in bytes of the NAL unit header and
+// slice segment header of a coded slice segment NAL unit, emulation
+// prevention bytes included. The parameter sets are looked up by id, the
+// multi layer and screen content extensions are not supported.
+func h265SliceHeaderSize(nalu []byte, spss map[uint32]*H265SPS, ppss map[uint32]*H265PPS) (int, error) {
+  if (len(nalu) < 3) {
+    return 0, errors.New("truncated slice segment");
+  }
+
+  typ := (nalu[0] >> 1) & 0x3f;
+  br := NewBitReader(UnescapeRBSP(nalu[2:]));
+
+  first := br.ReadFlag();
+
+  if (typ >= 16 && typ <= 23) {
+    // no_output_of_prior_pics_flag
+    br.Skip(1);
+  }
+
+  ppsId := br.ReadUE();
+
+  if (br.Err() != nil) {
+    return 0, br.Err();
+  }
+
+  pps := ppss[ppsId];
+
+  if (pps == nil) {
+    return 0, fmt.Errorf("unknown picture parameter set %d", ppsId);
+  }
+
+  sps := spss[pps.SPSId];
+
+  if (sps == nil) {
+    return 0, fmt.Errorf("unknown sequence parameter set %d", pps.SPSId);
+  }
+
+  dependent := false;
+
+  if (!first) {
+    if (pps.DependentSliceSegmentsEnabled) {
+      dependent = br.ReadFlag();
+    }
+    ctb := uint32(1) << sps.Log2CtbSize;
+    ctbs := ((sps.Width + ctb - 1) >> sps.Log2CtbSize) * ((sps.Height + ctb - 1) >> sps.Log2CtbSize);
+    br.Skip(ceilLog2(ctbs));
+  }
+
+  if (!dependent) {
+    br.Skip(int(pps.NumExtraSliceHeaderBits));
+
+    sliceType := br.ReadUE();
+    b := sliceType == 0;
+    p := sliceType == 1;
+
+    if (pps.OutputFlagPresent) {
+      br.Skip(1);
+    }
+
+    if (sps.SeparateColourPlane) {
+      br.Skip(2);
+    }
+
+    // ChromaArrayType is zero for monochrome and separate colour planes
+    chroma := sps.ChromaFormat != 0 && !sps.SeparateColourPlane;
+    numPicTotalCurr := 0;
+    temporalMvp := false;
+
+    if (typ != 19 && typ != 20) {
+      br.Skip(int(sps.Log2MaxPicOrderCntLsb));
+
+      var rps *h265RefPicSet;
+      num := int(sps.NumShortTermRefPicSets);
+
+      if (!br.ReadFlag()) {
+        set,err := parseH265RefPicSet(br, num, num, sps.stRefPicSets);
+        if (err != nil) {
+          return 0, err;
+        }
+        rps = &set;
+      } else {
+        idx := 0;
+        if (num > 1) {
+          idx = int(br.ReadBits(ceilLog2(uint32(num))));
+        }
+        if (idx >= num) {
+          return 0, errors.New("invalid short_term_ref_pic_set_idx");
+        }
+        rps = &sps.stRefPicSets[idx];
+      }
+
+      numPicTotalCurr = rps.numUsed();
+
+      if (sps.LongTermRefPicsPresent) {
+        numSps := uint32(0);
+        if (sps.NumLongTermRefPicsSps > 0) {
+          numSps = br.ReadUE();
+        }
+        numPics := br.ReadUE();
+        if (numSps > sps.NumLongTermRefPicsSps || numPics > 32) {
+          return 0, errors.New("invalid long term reference picture count");
+        }
+        for i := 0; i < int(numSps + numPics); i++ {
+          if (i < int(numSps)) {
+            idx := 0;
+            if (sps.NumLongTermRefPicsSps > 1) {
+              idx = int(br.ReadBits(ceilLog2(sps.NumLongTermRefPicsSps)));
+            }
+            if (idx < len(sps.usedByCurrPicLt) && sps.usedByCurrPicLt[idx]) {
+              numPicTotalCurr++;
+            }
+          } else {
+            br.Skip(int(sps.Log2MaxPicOrderCntLsb));
+            if (br.ReadFlag()) {
+              numPicTotalCurr++;
+            }
+          }
+          if (br.ReadFlag()) {
+            br.ReadUE();
+          }
+        }
+      }
+
+      if (sps.TemporalMvpEnabled) {
+        temporalMvp = br.ReadFlag();
+      }
+    }
+
+    saoLuma := false;
+    saoChroma := false;
+
+    if (sps.SampleAdaptiveOffsetEnabled) {
+      saoLuma = br.ReadFlag();
+      if (chroma) {
+        saoChroma = br.ReadFlag();
+      }
+    }
+
+    if (p || b) {
+      numRefIdx := []uint32{pps.NumRefIdxL0DefaultActive, pps.NumRefIdxL1DefaultActive};
+
+      if (br.ReadFlag()) {
+        numRefIdx[0] = br.ReadUE() + 1;
+        if (b) {
+          numRefIdx[1] = br.ReadUE() + 1;
+        }
+      }
+
+      if (numRefIdx[0] > 16 || numRefIdx[1] > 16) {
+        return 0, errors.New("invalid num_ref_idx_active");
+      }
+
+      if (!b) {
+        numRefIdx = numRefIdx[:1];
+      }
+
+      if (pps.ListsModificationPresent && numPicTotalCurr > 1) {
+        bits := ceilLog2(uint32(numPicTotalCurr));
+        for _,n := range numRefIdx {
+          if (br.ReadFlag()) {
+            br.Skip(int(n) * bits);
+          }
+        }
+      }
+
+      if (b) {
+        // mvd_l1_zero_flag
+        br.Skip(1);
+      }
+
+      if (pps.CabacInitPresent) {
+        br.Skip(1);
+      }
+
+      if (temporalMvp) {
+        fromL0 := true;
+        if (b) {
+          fromL0 = br.ReadFlag();
+        }
+        if ((fromL0 && numRefIdx[0] > 1) || (!fromL0 && numRefIdx[1] > 1)) {
+          br.ReadUE();
+        }
+      }
+
+      if ((pps.WeightedPred && p) || (pps.WeightedBipred && b)) {
+        skipH265PredWeightTable(br, chroma, numRefIdx);
+      }
+
+      // five_minus_max_num_merge_cand
+      br.ReadUE();
+    }
+
+    br.ReadSE();
+
+    if (pps.SliceChromaQpOffsetsPresent) {
+      br.ReadSE();
+      br.ReadSE();
+    }
+
+    if (pps.ChromaQpOffsetListEnabled) {
+      br.Skip(1);
+    }
+
+    disabled := pps.DeblockingFilterDisabled;
+
+    if (pps.DeblockingFilterOverrideEnabled && br.ReadFlag()) {
+      disabled = br.ReadFlag();
+      if (!disabled) {
+        br.ReadSE();
+        br.ReadSE();
+      }
+    }
+
+    if (pps.LoopFilterAcrossSlicesEnabled && (saoLuma || saoChroma || !disabled)) {
+      br.Skip(1);
+    }
+  }
+
+  if (pps.TilesEnabled || pps.EntropyCodingSyncEnabled) {
+    n := br.ReadUE();
+    if (n > 0) {
+      size := br.ReadUE() + 1;
+      if (size > 32) {
+        return 0, errors.New("invalid offset_len_minus1");
+      }
+      for i := 0; i < int(n) && br.Err() == nil; i++ {
+        br.Skip(int(size));
+      }
+    }
+  }
+
+  if (pps.SliceSegmentHeaderExtensionPresent) {
+    br.Skip(int(br.ReadUE()) * 8);
+  }
+
+  // byte_alignment()
+  br.Skip(8 - br.pos % 8);
+
+  if (br.Err() != nil) {
+    return 0, br.Err();
+  }
+
+  return 2 + escapedSize(nalu[2:], br.pos / 8), nil;
+}
@@ -0,0 +1,101 @@
+package mp4
+
+const (
+  HDR_FORMAT_SDR = "SDR"
+  HDR_FORMAT_HDR10 = "HDR10"
+  HDR_FORMAT_HLG = "HLG"
+  HDR_FORMAT_DOLBY_VISION = "Dolby Vision"
+)
+
+// transfer characteristics code points (ITU-T H.273)
+const (
+  TRANSFER_PQ = 16
+  TRANSFER_HLG = 18
+)
+
+type HDRInfo struct {
+  Format string `json:"format"`
+  ColourPrimaries uint16 `json:"colourPrimaries"`
+  TransferCharacteristics uint16 `json:"transferCharacteristics"`
+  MatrixCoefficients uint16 `json:"matrixCoefficients"`
+  MasteringDisplay *MasteringDisplayColourVolumeBox `json:"masteringDisplay"`
+  ContentLightLevel *ContentLightLevelBox `json:"contentLightLevel"`
+  DolbyVision *DolbyVisionConfigBox `json:"dolbyVision"`
+}
+
+// colourDescription looks for the colour description of the sample entry,
+// preferring colr over what the codec configuration carries. For AVC and
+// HEVC that is the VUI of the first SPS.
+func colourDescription(entry *SampleEntry) (uint16, uint16, uint16) {
+  var cp,tc,mc uint16;
+
+  for _,ext := range entry.Extensions {
+    switch v := ext.(type) {
+    case ColourInformationBox:
+      if (v.ColourType == "nclx" || v.ColourType == "nclc") {
+        return v.ColourPrimaries, v.TransferCharacteristics, v.MatrixCoefficients;
+      }
+    case VPcCBox:
+      cp,tc,mc = uint16(v.ColourPrimaries), uint16(v.TransferCharacteristics), uint16(v.MatrixCoefficients);
+    case AVCcBox:
+      spss,err := v.DecodeSPS();
+      if (err == nil && len(spss) > 0 && spss[0].VUI != nil && spss[0].VUI.ColourDescriptionPresent) {
+        vui := spss[0].VUI;
+        cp,tc,mc = uint16(vui.ColourPrimaries), uint16(vui.TransferCharacteristics), uint16(vui.MatrixCoefficients);
+      }
+    case HVCcBox:
+      spss,err := v.DecodeSPS();
+      if (err == nil && len(spss) > 0 && spss[0].VUI != nil && spss[0].VUI.ColourDescriptionPresent) {
+        vui := spss[0].VUI;
+        cp,tc,mc = uint16(vui.ColourPrimaries), uint16(vui.TransferCharacteristics), uint16(vui.MatrixCoefficients);
+      }
+    case AV1cBox:
+      if (v.SequenceHeader != nil && v.SequenceHeader.ColorDescriptionPresent) {
+        sh := v.SequenceHeader;
+        cp,tc,mc = uint16(sh.ColorPrimaries), uint16(sh.TransferCharacteristics), uint16(sh.MatrixCoefficients);
+      }
+    }
+  }
+
+  return cp, tc, mc;
+}
+
+// HDRInfo summarises the HDR signalling of the track's sample entry. A
+// Dolby Vision configuration takes precedence, then the transfer function
+// decides between HDR10 (PQ) and HLG.
+func (t *Track) HDRInfo() (*HDRInfo, error) {
+  entry,err := t.SampleEntry();
+
+  if (err != nil) {
+    return nil, err;
+  }
+
+  info := HDRInfo{Format: HDR_FORMAT_SDR};
+
+  info.ColourPrimaries,info.TransferCharacteristics,info.MatrixCoefficients = colourDescription(entry);
+
+  for _,ext := range entry.Extensions {
+    switch v := ext.(type) {
+    case MasteringDisplayColourVolumeBox:
+      mdcv := v;
+      info.MasteringDisplay = &mdcv;
+    case ContentLightLevelBox:
+      clli := v;
+      info.ContentLightLevel = &clli;
+    case DolbyVisionConfigBox:
+      dv := v;
+      info.DolbyVision = &dv;
+    }
+  }
+
+  switch {
+  case info.DolbyVision != nil:
+    info.Format = HDR_FORMAT_DOLBY_VISION;
+  case info.TransferCharacteristics == TRANSFER_PQ:
+    info.Format = HDR_FORMAT_HDR10;
+  case info.TransferCharacteristics == TRANSFER_HLG:
+    info.Format = HDR_FORMAT_HLG;
+  }
+
+  return &info, nil;
+}
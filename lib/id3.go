@@ -0,0 +1,242 @@
+package mp4
+
+import (
+  "bytes"
+  "errors"
+  "encoding/binary"
+  "unicode/utf16"
+)
+
+// common scheme_id_uri values for ID3 carried in emsg
+const (
+  EMSG_SCHEME_ID3 = "https://aomedia.org/emsg/ID3"
+  EMSG_SCHEME_ID3_APPLE = "https://developer.apple.com/streaming/emsg-id3"
+)
+
+type ID3Frame struct {
+  ID string `json:"id"`
+  Flags uint16 `json:"flags"`
+  Data []byte `json:"data"`
+}
+
+type ID3Tag struct {
+  Version uint8 `json:"version"`
+  Revision uint8 `json:"revision"`
+  Flags uint8 `json:"flags"`
+  Size uint32 `json:"size"`
+  Frames []ID3Frame `json:"frames"`
+}
+
+func readSyncSafe(data []byte) uint32 {
+  return uint32(data[0] & 0x7f) << 21 | uint32(data[1] & 0x7f) << 14 |
+    uint32(data[2] & 0x7f) << 7 | uint32(data[3] & 0x7f);
+}
+
+// removeUnsync reverses the ID3 unsynchronisation scheme, which inserts a
+// zero byte after every 0xff.
+func removeUnsync(data []byte) []byte {
+  return bytes.ReplaceAll(data, []byte{0xff, 0x00}, []byte{0xff});
+}
+
+// ParseID3v2 parses an ID3v2.2, v2.3 or v2.4 tag, as found in emsg message
+// data and in the samples of ID3 timed metadata tracks.
+func ParseID3v2(data []byte) (*ID3Tag, error) {
+  if (len(data) < 10 || string(data[0:3]) != "ID3") {
+    return nil, errors.New("invalid id3 header");
+  }
+
+  tag := ID3Tag{};
+  tag.Version = data[3];
+  tag.Revision = data[4];
+  tag.Flags = data[5];
+  tag.Size = readSyncSafe(data[6:10]);
+
+  if (tag.Version < 2 || tag.Version > 4) {
+    return nil, errors.New("unsupported id3 version");
+  }
+
+  if (uint32(len(data) - 10) < tag.Size) {
+    return nil, errors.New("not enough data");
+  }
+
+  body := data[10:10 + tag.Size];
+
+  if ((tag.Flags & 0x80) != 0 && tag.Version < 4) {
+    body = removeUnsync(body);
+  }
+
+  if ((tag.Flags & 0x40) != 0 && tag.Version > 2) {
+    if (len(body) < 4) {
+      return nil, errors.New("invalid id3 extended header");
+    }
+    var n uint32;
+    if (tag.Version == 3) {
+      n = binary.BigEndian.Uint32(body[0:4]) + 4;
+    } else {
+      n = readSyncSafe(body[0:4]);
+    }
+    if (uint32(len(body)) < n) {
+      return nil, errors.New("invalid id3 extended header");
+    }
+    body = body[n:];
+  }
+
+  hlen := 10;
+
+  if (tag.Version == 2) {
+    hlen = 6;
+  }
+
+  for len(body) >= hlen && body[0] != 0 {
+    frame := ID3Frame{};
+    var size uint32;
+
+    switch (tag.Version) {
+    case 2:
+      frame.ID = string(body[0:3]);
+      size = uint32(body[3]) << 16 | uint32(body[4]) << 8 | uint32(body[5]);
+    case 3:
+      frame.ID = string(body[0:4]);
+      size = binary.BigEndian.Uint32(body[4:8]);
+      frame.Flags = binary.BigEndian.Uint16(body[8:10]);
+    case 4:
+      frame.ID = string(body[0:4]);
+      size = readSyncSafe(body[4:8]);
+      frame.Flags = binary.BigEndian.Uint16(body[8:10]);
+    }
+
+    if (uint32(len(body) - hlen) < size) {
+      return nil, errors.New("invalid id3 frame size");
+    }
+
+    frame.Data = body[hlen:hlen + int(size)];
+
+    if (tag.Version == 4) {
+      if ((frame.Flags & 0x0002) != 0) {
+        frame.Data = removeUnsync(frame.Data);
+      }
+      // data length indicator
+      if ((frame.Flags & 0x0001) != 0 && len(frame.Data) >= 4) {
+        frame.Data = frame.Data[4:];
+      }
+    }
+
+    tag.Frames = append(tag.Frames, frame);
+
+    body = body[hlen + int(size):];
+  }
+
+  return &tag, nil;
+}
+
+// Frame returns the first frame with the given ID, or nil.
+func (tag *ID3Tag) Frame(id string) *ID3Frame {
+  for i := range tag.Frames {
+    if (tag.Frames[i].ID == id) {
+      return &tag.Frames[i];
+    }
+  }
+  return nil;
+}
+
+func decodeID3String(enc byte, data []byte) string {
+  switch (enc) {
+  case 1, 2:
+    order := binary.ByteOrder(binary.BigEndian);
+    if (len(data) >= 2 && data[0] == 0xff && data[1] == 0xfe) {
+      order = binary.LittleEndian;
+      data = data[2:];
+    } else if (len(data) >= 2 && data[0] == 0xfe && data[1] == 0xff) {
+      data = data[2:];
+    }
+    u := make([]uint16, 0, len(data) / 2);
+    for i := 0; i + 1 < len(data); i += 2 {
+      c := order.Uint16(data[i:]);
+      if (c == 0) {
+        break;
+      }
+      u = append(u, c);
+    }
+    return string(utf16.Decode(u));
+  case 3:
+    s,_ := readCString(data);
+    return s;
+  }
+
+  // ISO-8859-1
+  s,_ := readCString(data);
+  r := make([]rune, 0, len(s));
+  for i := 0; i < len(s); i++ {
+    r = append(r, rune(s[i]));
+  }
+  return string(r);
+}
+
+// splitID3String splits data after the first string terminator for the
+// given text encoding.
+func splitID3String(enc byte, data []byte) ([]byte, []byte) {
+  if (enc == 1 || enc == 2) {
+    for i := 0; i + 1 < len(data); i += 2 {
+      if (data[i] == 0 && data[i + 1] == 0) {
+        return data[:i], data[i + 2:];
+      }
+    }
+    return data, nil;
+  }
+
+  i := bytes.IndexByte(data, 0);
+
+  if (i < 0) {
+    return data, nil;
+  }
+
+  return data[:i], data[i + 1:];
+}
+
+// Text decodes a text information frame (T***). For TXXX frames the value
+// is returned, see Description for its key.
+func (f ID3Frame) Text() string {
+  if (len(f.Data) < 1 || len(f.ID) == 0 || f.ID[0] != 'T') {
+    return "";
+  }
+
+  enc := f.Data[0];
+  data := f.Data[1:];
+
+  if (f.ID == "TXXX" || f.ID == "TXX") {
+    _,data = splitID3String(enc, data);
+  }
+
+  return decodeID3String(enc, data);
+}
+
+// Description returns the description of TXXX and WXXX frames.
+func (f ID3Frame) Description() string {
+  if (len(f.Data) < 1) {
+    return "";
+  }
+
+  switch (f.ID) {
+  case "TXXX", "TXX", "WXXX", "WXX":
+    desc,_ := splitID3String(f.Data[0], f.Data[1:]);
+    return decodeID3String(f.Data[0], desc);
+  }
+
+  return "";
+}
+
+// Private splits a PRIV frame into its owner identifier and data.
+func (f ID3Frame) Private() (string, []byte) {
+  if (f.ID != "PRIV") {
+    return "", nil;
+  }
+
+  owner,data := readCString(f.Data);
+
+  return owner, data;
+}
+
+// ID3 parses the message data of an emsg carrying an ID3 tag.
+func (emsg EventMessageBox) ID3() (*ID3Tag, error) {
+  return ParseID3v2(emsg.MessageData);
+}
@@ -0,0 +1,129 @@
+package mp4
+
+import (
+  "errors"
+)
+
+const (
+  MPEG4_VISUAL_VOS_START_CODE = 0xb0
+  MPEG4_VISUAL_VOL_START_CODE_MIN = 0x20
+  MPEG4_VISUAL_VOL_START_CODE_MAX = 0x2f
+)
+
+const (
+  MPEG4_VISUAL_SHAPE_RECTANGULAR = 0
+  MPEG4_VISUAL_SHAPE_BINARY = 1
+  MPEG4_VISUAL_SHAPE_BINARY_ONLY = 2
+  MPEG4_VISUAL_SHAPE_GRAYSCALE = 3
+)
+
+type MPEG4VisualConfig struct {
+  // from the visual object sequence header, zero when missing
+  ProfileLevel uint8 `json:"profileLevel"`
+  ObjectType uint8 `json:"objectType"`
+  VerID uint8 `json:"verId"`
+  AspectRatioInfo uint8 `json:"aspectRatioInfo"`
+  ParWidth uint8 `json:"parWidth"`
+  ParHeight uint8 `json:"parHeight"`
+  ChromaFormat uint8 `json:"chromaFormat"`
+  LowDelay bool `json:"lowDelay"`
+  Shape uint8 `json:"shape"`
+  TimeIncrementResolution uint16 `json:"timeIncrementResolution"`
+  FixedVopRate bool `json:"fixedVopRate"`
+  FixedVopTimeIncrement uint16 `json:"fixedVopTimeIncrement"`
+  Width uint16 `json:"width"`
+  Height uint16 `json:"height"`
+  Interlaced bool `json:"interlaced"`
+}
+
+// ParseMPEG4VisualConfig decodes the visual object sequence and video
+// object layer headers found in the DecoderSpecificInfo of MPEG-4 Part 2
+// streams (ISO/IEC 14496-2 6.2.2 and 6.2.3).
+func ParseMPEG4VisualConfig(data []byte) (*MPEG4VisualConfig, error) {
+  cfg := MPEG4VisualConfig{};
+
+  for i := 0; i + 4 <= len(data); i++ {
+    if (data[i] != 0 || data[i + 1] != 0 || data[i + 2] != 1) {
+      continue;
+    }
+
+    code := data[i + 3];
+
+    if (code == MPEG4_VISUAL_VOS_START_CODE && i + 4 < len(data)) {
+      cfg.ProfileLevel = data[i + 4];
+    }
+
+    if (code >= MPEG4_VISUAL_VOL_START_CODE_MIN && code <= MPEG4_VISUAL_VOL_START_CODE_MAX) {
+      err := parseMPEG4VisualVOL(data[i + 4:], &cfg);
+      if (err != nil) {
+        return nil, err;
+      }
+      return &cfg, nil;
+    }
+  }
+
+  return nil, errors.New("video object layer header not found");
+}
+
+func parseMPEG4VisualVOL(data []byte, cfg *MPEG4VisualConfig) error {
+  br := NewBitReader(data);
+
+  // random_accessible_vol
+  br.Skip(1);
+  cfg.ObjectType = uint8(br.ReadBits(8));
+  cfg.VerID = 1;
+
+  if (br.ReadFlag()) {
+    cfg.VerID = uint8(br.ReadBits(4));
+    br.Skip(3);
+  }
+
+  cfg.AspectRatioInfo = uint8(br.ReadBits(4));
+
+  if (cfg.AspectRatioInfo == 0x0f) {
+    cfg.ParWidth = uint8(br.ReadBits(8));
+    cfg.ParHeight = uint8(br.ReadBits(8));
+  }
+
+  if (br.ReadFlag()) {
+    cfg.ChromaFormat = uint8(br.ReadBits(2));
+    cfg.LowDelay = br.ReadFlag();
+    if (br.ReadFlag()) {
+      // bit rate, vbv buffer size and occupancy with their markers
+      br.Skip(15 + 1 + 15 + 1 + 15 + 1 + 3 + 11 + 1 + 15 + 1);
+    }
+  }
+
+  cfg.Shape = uint8(br.ReadBits(2));
+
+  if (cfg.Shape == MPEG4_VISUAL_SHAPE_GRAYSCALE && cfg.VerID != 1) {
+    br.Skip(4);
+  }
+
+  br.Skip(1);
+  cfg.TimeIncrementResolution = uint16(br.ReadBits(16));
+  br.Skip(1);
+
+  cfg.FixedVopRate = br.ReadFlag();
+
+  if (cfg.FixedVopRate) {
+    n := 1;
+    for (1 << uint(n)) < int(cfg.TimeIncrementResolution) {
+      n++;
+    }
+    cfg.FixedVopTimeIncrement = uint16(br.ReadBits(n));
+  }
+
+  if (cfg.Shape != MPEG4_VISUAL_SHAPE_BINARY_ONLY) {
+    if (cfg.Shape == MPEG4_VISUAL_SHAPE_RECTANGULAR) {
+      br.Skip(1);
+      cfg.Width = uint16(br.ReadBits(13));
+      br.Skip(1);
+      cfg.Height = uint16(br.ReadBits(13));
+      br.Skip(1);
+    }
+    cfg.Interlaced = br.ReadFlag();
+  }
+
+  return br.Err();
+}
@@ -1,6 +1,7 @@
 package mp4
 
 import (
+  "io"
   "os"
   "fmt"
   "errors"
@@ -8,11 +9,12 @@ import (
 
 type MP4 struct {
   Boxes []interface{}
+  r io.ReaderAt
 }
 
 func Parse(f *os.File) (*MP4, error) {
 
-  res := MP4{make([]interface{}, 0)};
+  res := MP4{Boxes: make([]interface{}, 0), r: f};
 
   hdr := make([]byte, BOX_HDR_SZ_EXT);
 
@@ -70,6 +72,13 @@ func Parse(f *os.File) (*MP4, error) {
     case "moov":
       mb,_ := parseMovieBox(data, b);
       res.Boxes = append(res.Boxes, *mb);
+    case "emsg":
+      emsg,err := parseEventMessageBox(data, b);
+      if (err != nil) {
+        fmt.Println(err);
+        break;
+      }
+      res.Boxes = append(res.Boxes, *emsg);
     }
   }
 
@@ -77,3 +86,28 @@ func Parse(f *os.File) (*MP4, error) {
 
   return &res, nil;
 }
+
+// Movie returns a view over the parsed moov box whose tracks can read
+// samples back from the file passed to Parse.
+func (m *MP4) Movie() (*Movie, error) {
+  for _,box := range m.Boxes {
+    mb,ok := box.(MovieBox);
+    if (!ok) {
+      continue;
+    }
+    return newMovie(&mb, m.r);
+  }
+  return nil, errors.New("moov box not found");
+}
+
+// EventMessages returns the top level emsg boxes in file order.
+func (m *MP4) EventMessages() []EventMessageBox {
+  var events []EventMessageBox;
+  for _,box := range m.Boxes {
+    emsg,ok := box.(EventMessageBox);
+    if (ok) {
+      events = append(events, emsg);
+    }
+  }
+  return events;
+}
@@ -0,0 +1,125 @@
+package mp4
+
+import (
+  "fmt"
+  "errors"
+  "math/bits"
+  "encoding/binary"
+)
+
+// chan layout tags which do not encode the channel count
+const (
+  CHANNEL_LAYOUT_USE_DESCRIPTIONS = 0
+  CHANNEL_LAYOUT_USE_BITMAP = 0x10000
+)
+
+// ChannelCount returns the number of channels described by the layout,
+// which CoreAudio layout tags keep in their low 16 bits.
+func (chn ChannelLayoutBox) ChannelCount() int {
+  switch (chn.LayoutTag) {
+  case CHANNEL_LAYOUT_USE_DESCRIPTIONS:
+    return len(chn.Descriptions);
+  case CHANNEL_LAYOUT_USE_BITMAP:
+    return bits.OnesCount32(chn.Bitmap);
+  }
+  return int(chn.LayoutTag & 0xffff);
+}
+
+// tmcd sample entry flags
+const (
+  TIMECODE_FLAG_DROP_FRAME = 0x01
+  TIMECODE_FLAG_24_HOUR_MAX = 0x02
+  TIMECODE_FLAG_NEGATIVE_TIMES = 0x04
+  TIMECODE_FLAG_COUNTER = 0x08
+)
+
+func (tsd TimecodeSampleDescription) DropFrame() bool {
+  return (tsd.Flags & TIMECODE_FLAG_DROP_FRAME) != 0;
+}
+
+// FormatTimecode converts a frame number into a SMPTE timecode string at
+// the nominal fps, wrapping at 24 hours. Drop frame timecodes skip the
+// first frame numbers of every minute but each tenth and use a semicolon as
+// the last separator.
+func FormatTimecode(frame uint32, fps uint8, dropFrame bool) string {
+  if (fps == 0) {
+    return "";
+  }
+
+  n := uint64(frame);
+  rate := uint64(fps);
+  sep := ":";
+
+  if (dropFrame) {
+    // 2 frame numbers per minute at 30fps, 4 at 60fps
+    drop := (rate + 14) / 15;
+    perMin := rate * 60 - drop;
+    per10Min := rate * 600 - drop * 9;
+    d := n / per10Min;
+    m := n % per10Min;
+    n += drop * 9 * d;
+    if (m > drop) {
+      n += drop * ((m - drop) / perMin);
+    }
+    sep = ";";
+  }
+
+  ff := n % rate;
+  secs := n / rate;
+
+  return fmt.Sprintf("%02d:%02d:%02d%s%02d", (secs / 3600) % 24, (secs / 60) % 60, secs % 60, sep, ff);
+}
+
+// StartTimecode returns the SMPTE timecode of the first sample of a tmcd
+// track, which holds the frame number the movie starts at.
+func (t *Track) StartTimecode() (string, error) {
+  entry,err := t.SampleEntry();
+
+  if (err != nil) {
+    return "", err;
+  }
+
+  tsd,ok := entry.SampleDesc.(TimecodeSampleDescription);
+
+  if (!ok) {
+    return "", errors.New("not a timecode track");
+  }
+
+  if (len(t.samples) == 0) {
+    return "", errors.New("timecode track has no samples");
+  }
+
+  s,err := t.ReadSample(0);
+
+  if (err != nil) {
+    return "", err;
+  }
+
+  if (len(s.Data) < 4) {
+    return "", errors.New("invalid timecode sample");
+  }
+
+  frame := binary.BigEndian.Uint32(s.Data[0:4]);
+
+  if ((tsd.Flags & TIMECODE_FLAG_COUNTER) != 0) {
+    return fmt.Sprintf("%d", frame), nil;
+  }
+
+  fps := tsd.NumberOfFrames;
+
+  if (fps == 0 && tsd.FrameDuration != 0) {
+    fps = uint8((tsd.Timescale + tsd.FrameDuration / 2) / tsd.FrameDuration);
+  }
+
+  return FormatTimecode(frame, fps, tsd.DropFrame()), nil;
+}
+
+// StartTimecode returns the start timecode of the first tmcd track.
+func (m *Movie) StartTimecode() (string, error) {
+  for _,t := range m.Tracks {
+    if (t.Kind() == TRACK_KIND_TIMECODE) {
+      return t.StartTimecode();
+    }
+  }
+  return "", errors.New("no timecode track");
+}
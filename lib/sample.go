@@ -0,0 +1,407 @@
+package mp4
+
+import (
+  "sort"
+  "errors"
+)
+
+// sample flags, taken from sdtp and the sample group mappings
+const (
+  // is_leading 1 or 3
+  SAMPLE_FLAG_LEADING = 1 << iota
+  // is_leading 1, the sample cannot be decoded when starting at the
+  // preceding random access point
+  SAMPLE_FLAG_UNDECODABLE_LEADING
+  // sample_depends_on 2, e.g. an I picture
+  SAMPLE_FLAG_INDEPENDENT
+  // sample_is_depended_on 2, no other sample references it
+  SAMPLE_FLAG_DISPOSABLE
+  // sample_has_redundancy 1
+  SAMPLE_FLAG_REDUNDANT
+  // member of a rap group, e.g. the open GOP I pictures stss leaves out
+  SAMPLE_FLAG_RAP
+  // member of a sync group
+  SAMPLE_FLAG_SYNC
+  // member of a roll group, see RollDistance
+  SAMPLE_FLAG_ROLL
+  // member of a prol group, see RollDistance
+  SAMPLE_FLAG_PRE_ROLL
+  // member of a tele group with level_independently_decodable set
+  SAMPLE_FLAG_TEMPORAL_LEVEL_INDEPENDENT
+)
+
+// Sample holds the payload of a single media sample. DTS, PTS and Duration
+// are expressed in the track's media timescale (mdhd).
+type Sample struct {
+  Data []byte
+  DTS uint64
+  PTS int64
+  Duration uint32
+  Keyframe bool
+  // SAMPLE_FLAG_* bits
+  Flags uint32
+  // roll_distance of roll and prol groups, in samples
+  RollDistance int16
+  // from subs, nil when the sample is not sub-divided
+  Subsamples []SubSampleInfo
+}
+
+type sampleInfo struct {
+  offset uint64
+  size uint32
+  dts uint64
+  duration uint32
+  ctsOffset int32
+  keyframe bool
+  flags uint32
+  rollDistance int16
+  subsamples []SubSampleInfo
+}
+
+func (s *sampleInfo) pts() int64 {
+  return int64(s.dts) + int64(s.ctsOffset);
+}
+
+// buildPresentationOrder sorts the sample indexes by presentation time,
+// samples presented at the same time keep their decode order.
+func buildPresentationOrder(samples []sampleInfo) []int {
+  order := make([]int, len(samples));
+
+  for i := range order {
+    order[i] = i;
+  }
+
+  sort.SliceStable(order, func(a, b int) bool {
+    return samples[order[a]].pts() < samples[order[b]].pts();
+  });
+
+  return order;
+}
+
+// chunkOffsets returns the chunk offset table, from co64 when the file uses
+// 64 bit offsets.
+func chunkOffsets(stbl *SampleTableBox) []uint64 {
+  if (stbl.Co64.Box.Box.Type != "") {
+    return stbl.Co64.ChunkOffset;
+  }
+
+  offsets := make([]uint64, len(stbl.Stco.ChunkOffset));
+
+  for i,off := range stbl.Stco.ChunkOffset {
+    offsets[i] = uint64(off);
+  }
+
+  return offsets;
+}
+
+func buildSampleIndex(stbl *SampleTableBox) ([]sampleInfo, error) {
+  n := int(stbl.Stsz.SampleCount);
+
+  samples := make([]sampleInfo, n);
+
+  if (stbl.Stsz.SampleSize == 0 && len(stbl.Stsz.EntrySize) != n) {
+    return nil, errors.New("invalid sample size table");
+  }
+
+  for i := 0; i < n; i++ {
+    if (stbl.Stsz.SampleSize != 0) {
+      samples[i].size = stbl.Stsz.SampleSize;
+    } else {
+      samples[i].size = stbl.Stsz.EntrySize[i];
+    }
+  }
+
+  idx := 0;
+  var dts uint64;
+
+  for i := 0; i < int(stbl.Stts.EntryCount); i++ {
+    for j := 0; j < int(stbl.Stts.SampleCount[i]) && idx < n; j++ {
+      samples[idx].dts = dts;
+      samples[idx].duration = stbl.Stts.SampleDelta[i];
+      dts += uint64(stbl.Stts.SampleDelta[i]);
+      idx++;
+    }
+  }
+
+  idx = 0;
+
+  for i := 0; i < int(stbl.Ctss.EntryCount); i++ {
+    for j := 0; j < int(stbl.Ctss.SampleCount[i]) && idx < n; j++ {
+      samples[idx].ctsOffset = stbl.Ctss.SampleOffset[i];
+      idx++;
+    }
+  }
+
+  // no stss box means every sample is a sync sample
+  if (stbl.Stss.Box.Box.Type == "") {
+    for i := range samples {
+      samples[i].keyframe = true;
+    }
+  } else {
+    for _,num := range stbl.Stss.SampleNumber {
+      if (num > 0 && int(num) <= n) {
+        samples[num - 1].keyframe = true;
+      }
+    }
+  }
+
+  idx = 0;
+  offsets := chunkOffsets(stbl);
+  nchunks := len(offsets);
+
+  for i := 0; i < int(stbl.Stsc.EntryCount); i++ {
+    first := int(stbl.Stsc.FirstChunk[i]);
+    last := nchunks;
+
+    if (i + 1 < int(stbl.Stsc.EntryCount)) {
+      last = int(stbl.Stsc.FirstChunk[i + 1]) - 1;
+    }
+
+    if (first < 1 || last > nchunks) {
+      return nil, errors.New("invalid sample to chunk table");
+    }
+
+    for c := first; c <= last; c++ {
+      offset := offsets[c - 1];
+      for j := 0; j < int(stbl.Stsc.SamplesPerChunk[i]) && idx < n; j++ {
+        samples[idx].offset = offset;
+        offset += uint64(samples[idx].size);
+        idx++;
+      }
+    }
+  }
+
+  if (idx != n) {
+    return nil, errors.New("sample to chunk table does not cover all samples");
+  }
+
+  applySampleDependencies(stbl, samples);
+  applySampleGroups(stbl, samples);
+
+  // sample_delta counts from the previous entry, the first one from zero
+  idx = 0;
+
+  for _,e := range stbl.Subs.Entries {
+    idx += int(e.SampleDelta);
+    if (idx < 1 || idx > n) {
+      break;
+    }
+    samples[idx - 1].subsamples = e.Subsamples;
+  }
+
+  return samples, nil;
+}
+
+func applySampleDependencies(stbl *SampleTableBox, samples []sampleInfo) {
+  for i,v := range stbl.Sdtp.Entries {
+    if (i >= len(samples)) {
+      break;
+    }
+
+    leading := v >> 6;
+    dependsOn := (v >> 4) & 0x03;
+    dependedOn := (v >> 2) & 0x03;
+
+    if (leading == 1 || leading == 3) {
+      samples[i].flags |= SAMPLE_FLAG_LEADING;
+    }
+    if (leading == 1) {
+      samples[i].flags |= SAMPLE_FLAG_UNDECODABLE_LEADING;
+    }
+    if (dependsOn == 2) {
+      samples[i].flags |= SAMPLE_FLAG_INDEPENDENT;
+    }
+    if (dependedOn == 2) {
+      samples[i].flags |= SAMPLE_FLAG_DISPOSABLE;
+    }
+    if ((v & 0x03) == 1) {
+      samples[i].flags |= SAMPLE_FLAG_REDUNDANT;
+    }
+  }
+}
+
+// sampleGroupIndex resolves the sbgp mappings of n samples to 1-based
+// entries of sgpd, zero meaning no group. Samples left unmapped use the
+// default index of version 2 sgpd.
+func sampleGroupIndex(stbl *SampleTableBox, sgpd *SampleGroupDescriptionBox, n int) []uint32 {
+  index := make([]uint32, n);
+
+  if (sgpd.Box.Version >= 2) {
+    for i := range index {
+      index[i] = sgpd.DefaultSampleDescriptionIndex;
+    }
+  }
+
+  for _,sbgp := range stbl.Sbgp {
+    if (sbgp.GroupingType != sgpd.GroupingType) {
+      continue;
+    }
+    idx := 0;
+    for i := 0; i < int(sbgp.EntryCount); i++ {
+      for j := 0; j < int(sbgp.SampleCount[i]) && idx < n; j++ {
+        index[idx] = sbgp.GroupDescriptionIndex[i];
+        idx++;
+      }
+    }
+  }
+
+  return index;
+}
+
+// applySampleGroups sets the flags of the samples mapped to roll, rap, sync
+// and tele groups.
+func applySampleGroups(stbl *SampleTableBox, samples []sampleInfo) {
+  for k := range stbl.Sgpd {
+    sgpd := &stbl.Sgpd[k];
+    index := sampleGroupIndex(stbl, sgpd, len(samples));
+
+    for i,gdi := range index {
+      if (gdi == 0 || int(gdi) > len(sgpd.Entries)) {
+        continue;
+      }
+
+      s := &samples[i];
+
+      switch e := sgpd.Entries[gdi - 1].(type) {
+      case RollRecoveryEntry:
+        if (sgpd.GroupingType == "prol") {
+          s.flags |= SAMPLE_FLAG_PRE_ROLL;
+        } else {
+          s.flags |= SAMPLE_FLAG_ROLL;
+        }
+        s.rollDistance = e.RollDistance;
+      case VisualRandomAccessEntry:
+        s.flags |= SAMPLE_FLAG_RAP;
+      case SyncSampleEntry:
+        s.flags |= SAMPLE_FLAG_SYNC;
+      case TemporalLevelEntry:
+        if (e.LevelIndependentlyDecodable) {
+          s.flags |= SAMPLE_FLAG_TEMPORAL_LEVEL_INDEPENDENT;
+        }
+      }
+    }
+  }
+}
+
+func (t *Track) sampleAt(i int) (*Sample, error) {
+  if (t.err != nil) {
+    return nil, t.err;
+  }
+
+  if (i < 0 || i >= len(t.samples)) {
+    return nil, errors.New("sample index out of range");
+  }
+
+  info := t.samples[i];
+
+  s := Sample{
+    DTS: info.dts,
+    PTS: int64(info.dts) + int64(info.ctsOffset),
+    Duration: info.duration,
+    Keyframe: info.keyframe,
+    Flags: info.flags,
+    RollDistance: info.rollDistance,
+    Subsamples: info.subsamples,
+  };
+
+  return &s, nil;
+}
+
+// ReadSample reads the i-th sample (zero based) into a newly allocated buffer.
+func (t *Track) ReadSample(i int) (*Sample, error) {
+  return t.ReadSampleInto(i, nil);
+}
+
+// ReadSampleInto is like ReadSample but reuses buf when it is large enough
+// to hold the sample data. Samples of encrypted tracks are returned in the
+// clear once SetDecryptionKeys has been called.
+func (t *Track) ReadSampleInto(i int, buf []byte) (*Sample, error) {
+  s,err := t.sampleAt(i);
+
+  if (err != nil) {
+    return nil, err;
+  }
+
+  if (t.r == nil) {
+    return nil, errors.New("track has no reader");
+  }
+
+  size := int(t.samples[i].size);
+
+  if (cap(buf) < size) {
+    buf = make([]byte, size);
+  }
+
+  s.Data = buf[:size];
+
+  n,err := t.r.ReadAt(s.Data, int64(t.samples[i].offset));
+
+  if (n != size) {
+    if (err == nil) {
+      err = errors.New("not enough data");
+    }
+    return nil, err;
+  }
+
+  if (t.decrypter != nil) {
+    err = t.decrypter.decryptSample(i, s.Data);
+    if (err != nil) {
+      return nil, err;
+    }
+  }
+
+  return s, nil;
+}
+
+type SampleIterator struct {
+  t *Track
+  next int
+  reuse bool
+  buf []byte
+  cur *Sample
+  err error
+}
+
+// Samples returns an iterator over all the samples of the track. When reuse
+// is set the data buffer is shared between calls to Next, so Sample().Data
+// is only valid until the following call.
+func (t *Track) Samples(reuse bool) *SampleIterator {
+  return &SampleIterator{t: t, reuse: reuse, err: t.err};
+}
+
+func (it *SampleIterator) Next() bool {
+  if (it.err != nil || it.next >= len(it.t.samples)) {
+    return false;
+  }
+
+  var buf []byte;
+
+  if (it.reuse) {
+    buf = it.buf;
+  }
+
+  s,err := it.t.ReadSampleInto(it.next, buf);
+
+  if (err != nil) {
+    it.err = err;
+    it.cur = nil;
+    return false;
+  }
+
+  if (it.reuse) {
+    it.buf = s.Data;
+  }
+
+  it.cur = s;
+  it.next++;
+
+  return true;
+}
+
+func (it *SampleIterator) Sample() *Sample {
+  return it.cur;
+}
+
+func (it *SampleIterator) Err() error {
+  return it.err;
+}
@@ -0,0 +1,188 @@
+package mp4
+
+import (
+  "sort"
+  "time"
+  "errors"
+)
+
+type SeekPosition struct {
+  Track *Track
+  Sample int
+  Keyframe int
+}
+
+func durationToUnits(d time.Duration, timescale uint32) int64 {
+  ts := int64(timescale);
+  return int64(d / time.Second) * ts + int64(d % time.Second) * ts / int64(time.Second);
+}
+
+// mediaTime maps a presentation time to the track's media timeline,
+// applying the edit list when there is one. Dwell edits (media_rate 0)
+// hold the sample at their media_time, other rates are taken as 1.
+func (t *Track) mediaTime(d time.Duration) int64 {
+  elst := &t.Box.Edts.Elst;
+  mts := t.Box.Mdia.Mdhd.Timescale;
+
+  if (elst.EntryCount == 0 || t.movieTimescale == 0) {
+    return durationToUnits(d, mts);
+  }
+
+  var start time.Duration;
+  var mt int64;
+
+  for i := 0; i < int(elst.EntryCount); i++ {
+    dur := TimescaleToDuration(elst.SegmentDuration[i], t.movieTimescale);
+
+    // empty edit, nothing from this track is presented
+    if (elst.MediaTime[i] == -1) {
+      start += dur;
+      continue;
+    }
+
+    off := d - start;
+
+    if (off < 0) {
+      off = 0;
+    }
+
+    mt = elst.MediaTime[i];
+
+    if (elst.MediaRateInteger[i] != 0 || elst.MediaRateFraction[i] != 0) {
+      mt += durationToUnits(off, mts);
+    }
+
+    if (dur == 0 || off < dur) {
+      break;
+    }
+
+    start += dur;
+  }
+
+  return mt;
+}
+
+// presentationTime maps a media time back to the presentation timeline.
+// Only the leading empty edits and the first media edit are honoured, which
+// covers the offset and delay edit lists written by muxers.
+func (t *Track) presentationTime(mt int64) time.Duration {
+  elst := &t.Box.Edts.Elst;
+  mts := t.Box.Mdia.Mdhd.Timescale;
+
+  var start time.Duration;
+
+  for i := 0; i < int(elst.EntryCount) && t.movieTimescale != 0; i++ {
+    if (elst.MediaTime[i] == -1) {
+      start += TimescaleToDuration(elst.SegmentDuration[i], t.movieTimescale);
+      continue;
+    }
+    mt -= elst.MediaTime[i];
+    break;
+  }
+
+  if (mt < 0) {
+    return start;
+  }
+
+  return start + TimescaleToDuration(uint64(mt), mts);
+}
+
+func (t *Track) sampleAtMediaTime(mt int64) int {
+  n := len(t.samples);
+
+  if (t.Box.Mdia.Minf.Stbl.Ctss.EntryCount == 0) {
+    i := sort.Search(n, func(i int) bool {
+      return int64(t.samples[i].dts) > mt;
+    });
+    if (i > 0) {
+      i--;
+    }
+    return i;
+  }
+
+  // composition offsets break decode order, pick the latest sample
+  // presented at or before mt
+  order := t.presentationOrder;
+
+  i := sort.Search(n, func(i int) bool {
+    return t.samples[order[i]].pts() > mt;
+  });
+
+  if (i == 0) {
+    return 0;
+  }
+
+  // of the samples sharing that time, the first in decode order
+  pts := t.samples[order[i - 1]].pts();
+
+  j := sort.Search(i, func(j int) bool {
+    return t.samples[order[j]].pts() >= pts;
+  });
+
+  return order[j];
+}
+
+// SampleAtTime returns the index of the sample presented at or before d.
+// Times before the first sample map to the first sample and times past the
+// end to the last one.
+func (t *Track) SampleAtTime(d time.Duration) (int, error) {
+  if (t.err != nil) {
+    return 0, t.err;
+  }
+
+  if (len(t.samples) == 0) {
+    return 0, errors.New("track has no samples");
+  }
+
+  if (t.Box.Mdia.Mdhd.Timescale == 0) {
+    return 0, errors.New("invalid media timescale");
+  }
+
+  return t.sampleAtMediaTime(t.mediaTime(d)), nil;
+}
+
+// KeyframeBefore returns the index of the nearest sync sample at or before
+// the sample presented at d.
+func (t *Track) KeyframeBefore(d time.Duration) (int, error) {
+  i,err := t.SampleAtTime(d);
+
+  if (err != nil) {
+    return 0, err;
+  }
+
+  for ; i > 0; i-- {
+    if (t.samples[i].keyframe) {
+      break;
+    }
+  }
+
+  return i, nil;
+}
+
+// SeekAll resolves d on every track of the movie. Tracks with no samples
+// are skipped.
+func (m *Movie) SeekAll(d time.Duration) ([]SeekPosition, error) {
+  var res []SeekPosition;
+
+  for _,t := range m.Tracks {
+    if (len(t.samples) == 0) {
+      continue;
+    }
+
+    s,err := t.SampleAtTime(d);
+
+    if (err != nil) {
+      return nil, err;
+    }
+
+    k,err := t.KeyframeBefore(d);
+
+    if (err != nil) {
+      return nil, err;
+    }
+
+    res = append(res, SeekPosition{Track: t, Sample: s, Keyframe: k});
+  }
+
+  return res, nil;
+}
@@ -3,6 +3,11 @@ package mp4
 const BOX_HDR_SZ = 8;
 const BOX_HDR_SZ_EXT = 16;
 
+const MP4_ES_DESCR_TAG = 0x03;
+const MP4_DECODER_CONFIG_DESCR_TAG = 0x04;
+const MP4_DEC_SPECIFIC_INFO_TAG = 0x05;
+const MP4_SL_CONFIG_DESCR_TAG = 0x06;
+
 type Box struct {
   Type string `json:"type"`
   Size uint64 `json:"size"`
@@ -32,6 +37,20 @@ type MediaDataBox struct {
   data []byte
 }
 
+type EventMessageBox struct {
+  Box FullBox `json:"fullBox"`
+  SchemeIdURI string `json:"schemeIdUri"`
+  Value string `json:"value"`
+  Timescale uint32 `json:"timescale"`
+  // version 0 only
+  PresentationTimeDelta uint32 `json:"presentationTimeDelta"`
+  // version 1 only
+  PresentationTime uint64 `json:"presentationTime"`
+  EventDuration uint32 `json:"eventDuration"`
+  Id uint32 `json:"id"`
+  MessageData []byte `json:"messageData"`
+}
+
 type MovieHeaderBox struct {
   Box FullBox `json:"fullBox"`
   Ctime uint64 `json:"creationTime"`
@@ -42,6 +61,8 @@ type MovieHeaderBox struct {
   Volume float32 `json:"volume"`
   Matrix [3][3]float32 `json:"matrix"`
   NextTrackID uint32 `json:"nextTrackID"`
+  // set by WithJSONTimes
+  jsonTimes bool
 }
 
 type TrackHeaderBox struct {
@@ -52,6 +73,7 @@ type TrackHeaderBox struct {
   Duration uint64 `json:"duration"`
   Width float32 `json:"width"`
   Height float32 `json:"height"`
+  jsonTimes bool
 }
 
 type MediaHeaderBox struct {
@@ -61,6 +83,7 @@ type MediaHeaderBox struct {
   Timescale uint32 `json:"timescale"`
   Duration uint64 `json:"duration"`
   Language string `json:"language"`
+  jsonTimes bool
 }
 
 type HandlerBox struct {
@@ -73,10 +96,71 @@ type AVCcBox struct {
   Box Box `json:"box"`
   Version uint8 `json:"version"`
   Profile uint8 `json:"profile"`
+  Compatibility uint8 `json:"compatibility"`
   Level uint8 `json:"level"`
   SizeLen uint8 `json:"sizeLen"`
   SPS [][]byte `json:"sps"`
   PPS [][]byte `json:"pps"`
+  HasExtension bool `json:"hasExtension"`
+  ChromaFormat uint8 `json:"chromaFormat"`
+  BitDepthLuma uint8 `json:"bitDepthLuma"`
+  BitDepthChroma uint8 `json:"bitDepthChroma"`
+  SPSExt [][]byte `json:"spsExt"`
+}
+
+type HVCcBox struct {
+  Box Box `json:"box"`
+  Version uint8 `json:"version"`
+  ProfileSpace uint8 `json:"profileSpace"`
+  TierFlag uint8 `json:"tierFlag"`
+  ProfileIdc uint8 `json:"profileIdc"`
+  ProfileCompatibility uint32 `json:"profileCompatibility"`
+  ConstraintIndicator [6]byte `json:"constraintIndicator"`
+  Level uint8 `json:"level"`
+  MinSpatialSegmentation uint16 `json:"minSpatialSegmentation"`
+  ParallelismType uint8 `json:"parallelismType"`
+  ChromaFormat uint8 `json:"chromaFormat"`
+  BitDepthLuma uint8 `json:"bitDepthLuma"`
+  BitDepthChroma uint8 `json:"bitDepthChroma"`
+  AvgFrameRate uint16 `json:"avgFrameRate"`
+  ConstantFrameRate uint8 `json:"constantFrameRate"`
+  NumTemporalLayers uint8 `json:"numTemporalLayers"`
+  TemporalIdNested bool `json:"temporalIdNested"`
+  SizeLen uint8 `json:"sizeLen"`
+  VPS [][]byte `json:"vps"`
+  SPS [][]byte `json:"sps"`
+  PPS [][]byte `json:"pps"`
+  SEI [][]byte `json:"sei"`
+}
+
+type AV1cBox struct {
+  Box Box `json:"box"`
+  Version uint8 `json:"version"`
+  SeqProfile uint8 `json:"seqProfile"`
+  SeqLevelIdx uint8 `json:"seqLevelIdx"`
+  SeqTier uint8 `json:"seqTier"`
+  BitDepth uint8 `json:"bitDepth"`
+  Monochrome bool `json:"monochrome"`
+  ChromaSubsamplingX uint8 `json:"chromaSubsamplingX"`
+  ChromaSubsamplingY uint8 `json:"chromaSubsamplingY"`
+  ChromaSamplePosition uint8 `json:"chromaSamplePosition"`
+  InitialPresentationDelayPresent bool `json:"initialPresentationDelayPresent"`
+  InitialPresentationDelay uint8 `json:"initialPresentationDelay"`
+  ConfigOBUs []byte `json:"configOBUs"`
+  SequenceHeader *AV1SequenceHeader `json:"sequenceHeader"`
+}
+
+type VPcCBox struct {
+  Box FullBox `json:"fullBox"`
+  Profile uint8 `json:"profile"`
+  Level uint8 `json:"level"`
+  BitDepth uint8 `json:"bitDepth"`
+  ChromaSubsampling uint8 `json:"chromaSubsampling"`
+  FullRange bool `json:"fullRange"`
+  ColourPrimaries uint8 `json:"colourPrimaries"`
+  TransferCharacteristics uint8 `json:"transferCharacteristics"`
+  MatrixCoefficients uint8 `json:"matrixCoefficients"`
+  CodecInitData []byte `json:"codecInitData"`
 }
 
 type PixelAspectRatioBox struct {
@@ -85,17 +169,186 @@ type PixelAspectRatioBox struct {
   VSpacing uint32 `json:"vSpacing"`
 }
 
+type ColourInformationBox struct {
+  Box Box `json:"box"`
+  ColourType string `json:"colourType"`
+  ColourPrimaries uint16 `json:"colourPrimaries"`
+  TransferCharacteristics uint16 `json:"transferCharacteristics"`
+  MatrixCoefficients uint16 `json:"matrixCoefficients"`
+  FullRange bool `json:"fullRange"`
+  ICCProfile []byte `json:"iccProfile"`
+}
+
+type CleanApertureBox struct {
+  Box Box `json:"box"`
+  WidthN uint32 `json:"widthN"`
+  WidthD uint32 `json:"widthD"`
+  HeightN uint32 `json:"heightN"`
+  HeightD uint32 `json:"heightD"`
+  HorizOffN int32 `json:"horizOffN"`
+  HorizOffD uint32 `json:"horizOffD"`
+  VertOffN int32 `json:"vertOffN"`
+  VertOffD uint32 `json:"vertOffD"`
+}
+
+type BitRateBox struct {
+  Box Box `json:"box"`
+  BufferSizeDB uint32 `json:"bufferSizeDB"`
+  MaxBitrate uint32 `json:"maxBitrate"`
+  AvgBitrate uint32 `json:"avgBitrate"`
+}
+
+type FieldHandlingBox struct {
+  Box Box `json:"box"`
+  FieldCount uint8 `json:"fieldCount"`
+  FieldOrdering uint8 `json:"fieldOrdering"`
+}
+
+type GammaBox struct {
+  Box Box `json:"box"`
+  Gamma float32 `json:"gamma"`
+}
+
+type MasteringDisplayColourVolumeBox struct {
+  Box Box `json:"box"`
+  // green, blue, red
+  DisplayPrimariesX [3]uint16 `json:"displayPrimariesX"`
+  DisplayPrimariesY [3]uint16 `json:"displayPrimariesY"`
+  WhitePointX uint16 `json:"whitePointX"`
+  WhitePointY uint16 `json:"whitePointY"`
+  MaxLuminance uint32 `json:"maxLuminance"`
+  MinLuminance uint32 `json:"minLuminance"`
+}
+
+type ContentLightLevelBox struct {
+  Box Box `json:"box"`
+  MaxContentLightLevel uint16 `json:"maxContentLightLevel"`
+  MaxPicAverageLightLevel uint16 `json:"maxPicAverageLightLevel"`
+}
+
+type ContentColourVolumeBox struct {
+  Box Box `json:"box"`
+  Cancel bool `json:"cancel"`
+  Persistence bool `json:"persistence"`
+  PrimariesPresent bool `json:"primariesPresent"`
+  PrimariesX [3]int32 `json:"primariesX"`
+  PrimariesY [3]int32 `json:"primariesY"`
+  MinLuminancePresent bool `json:"minLuminancePresent"`
+  MinLuminance uint32 `json:"minLuminance"`
+  MaxLuminancePresent bool `json:"maxLuminancePresent"`
+  MaxLuminance uint32 `json:"maxLuminance"`
+  AvgLuminancePresent bool `json:"avgLuminancePresent"`
+  AvgLuminance uint32 `json:"avgLuminance"`
+}
+
+type AmbientViewingEnvironmentBox struct {
+  Box Box `json:"box"`
+  AmbientIlluminance uint32 `json:"ambientIlluminance"`
+  AmbientLightX uint16 `json:"ambientLightX"`
+  AmbientLightY uint16 `json:"ambientLightY"`
+}
+
+type DolbyVisionConfigBox struct {
+  Box Box `json:"box"`
+  VersionMajor uint8 `json:"versionMajor"`
+  VersionMinor uint8 `json:"versionMinor"`
+  Profile uint8 `json:"profile"`
+  Level uint8 `json:"level"`
+  RPUPresent bool `json:"rpuPresent"`
+  ELPresent bool `json:"elPresent"`
+  BLPresent bool `json:"blPresent"`
+  BLSignalCompatibilityID uint8 `json:"blSignalCompatibilityId"`
+}
+
+type Stereoscopic3DBox struct {
+  Box FullBox `json:"fullBox"`
+  StereoMode uint8 `json:"stereoMode"`
+}
+
+type EquirectangularProjection struct {
+  BoundsTop uint32 `json:"boundsTop"`
+  BoundsBottom uint32 `json:"boundsBottom"`
+  BoundsLeft uint32 `json:"boundsLeft"`
+  BoundsRight uint32 `json:"boundsRight"`
+}
+
+type CubemapProjection struct {
+  Layout uint32 `json:"layout"`
+  Padding uint32 `json:"padding"`
+}
+
+type ProjectionBox struct {
+  Box Box `json:"box"`
+  Yaw float32 `json:"yaw"`
+  Pitch float32 `json:"pitch"`
+  Roll float32 `json:"roll"`
+  // equi, cbmp or mshp
+  ProjectionType string `json:"projectionType"`
+  Equirectangular *EquirectangularProjection `json:"equirectangular"`
+  Cubemap *CubemapProjection `json:"cubemap"`
+}
+
+type SphericalVideoBox struct {
+  Box Box `json:"box"`
+  MetadataSource string `json:"metadataSource"`
+  Projection ProjectionBox `json:"projection"`
+}
+
+type VideoExtendedUsageBox struct {
+  Box Box `json:"box"`
+  HasLeftEye bool `json:"hasLeftEye"`
+  HasRightEye bool `json:"hasRightEye"`
+  HasAdditionalViews bool `json:"hasAdditionalViews"`
+  EyeViewsReversed bool `json:"eyeViewsReversed"`
+  HeroEye uint8 `json:"heroEye"`
+  // in micrometers
+  Baseline uint32 `json:"baseline"`
+  DisparityAdjustment int32 `json:"disparityAdjustment"`
+  ProjectionKind string `json:"projectionKind"`
+}
+
 type VideoSampleDescription struct {
   Width uint16 `json:"width"`
   Height uint16 `json:"height"`
+  HorizResolution float32 `json:"horizResolution"`
+  VertResolution float32 `json:"vertResolution"`
+  FrameCount uint16 `json:"frameCount"`
+  CompressorName string `json:"compressorName"`
   Depth uint16 `json:"depth"`
 }
 
+type DecoderConfigDescriptor struct {
+  ObjectTypeIndication uint8 `json:"objectTypeIndication"`
+  StreamType uint8 `json:"streamType"`
+  UpStream bool `json:"upStream"`
+  BufferSizeDB uint32 `json:"bufferSizeDB"`
+  MaxBitrate uint32 `json:"maxBitrate"`
+  AvgBitrate uint32 `json:"avgBitrate"`
+  DecoderSpecificInfo []byte `json:"decoderSpecificInfo"`
+}
+
+type SLConfigDescriptor struct {
+  Predefined uint8 `json:"predefined"`
+}
+
 type ESDescriptor struct {
   Tag uint8 `json:"tag"`
-  Length uint8 `json:"length"`
+  Length uint32 `json:"length"`
   Id uint16 `json:"id"`
+  StreamDependence bool `json:"streamDependence"`
+  URLFlag bool `json:"urlFlag"`
+  OCRStream bool `json:"ocrStream"`
+  StreamPriority uint8 `json:"streamPriority"`
+  DependsOnId uint16 `json:"dependsOnId"`
+  URL string `json:"url"`
+  OCRId uint16 `json:"ocrId"`
+  DecoderConfig DecoderConfigDescriptor `json:"decoderConfig"`
+  SLConfig SLConfigDescriptor `json:"slConfig"`
+  // shortcuts into DecoderConfig
+  ObjectType uint8 `json:"objectType"`
   Config []byte `json:"config"`
+  AudioConfig *AudioSpecificConfig `json:"audioConfig"`
+  VisualConfig *MPEG4VisualConfig `json:"visualConfig"`
 }
 
 type ElementaryStreamDescBox struct {
@@ -103,10 +356,265 @@ type ElementaryStreamDescBox struct {
   Esd ESDescriptor `json:"esd"`
 }
 
+type H263SpecificBox struct {
+  Box Box `json:"box"`
+  Vendor string `json:"vendor"`
+  DecoderVersion uint8 `json:"decoderVersion"`
+  Level uint8 `json:"level"`
+  Profile uint8 `json:"profile"`
+  // from the optional bitr box
+  AvgBitrate uint32 `json:"avgBitrate"`
+  MaxBitrate uint32 `json:"maxBitrate"`
+}
+
+type AMRSpecificBox struct {
+  Box Box `json:"box"`
+  Vendor string `json:"vendor"`
+  DecoderVersion uint8 `json:"decoderVersion"`
+  ModeSet uint16 `json:"modeSet"`
+  ModeChangePeriod uint8 `json:"modeChangePeriod"`
+  FramesPerSample uint8 `json:"framesPerSample"`
+}
+
+type PCMConfigBox struct {
+  Box FullBox `json:"fullBox"`
+  FormatFlags uint8 `json:"formatFlags"`
+  SampleSize uint8 `json:"sampleSize"`
+}
+
+type OpusSpecificBox struct {
+  Box Box `json:"box"`
+  Version uint8 `json:"version"`
+  OutputChannelCount uint8 `json:"outputChannelCount"`
+  PreSkip uint16 `json:"preSkip"`
+  InputSampleRate uint32 `json:"inputSampleRate"`
+  OutputGain int16 `json:"outputGain"`
+  ChannelMappingFamily uint8 `json:"channelMappingFamily"`
+  StreamCount uint8 `json:"streamCount"`
+  CoupledCount uint8 `json:"coupledCount"`
+  ChannelMapping []uint8 `json:"channelMapping"`
+}
+
+type FLACStreamInfo struct {
+  MinBlockSize uint16 `json:"minBlockSize"`
+  MaxBlockSize uint16 `json:"maxBlockSize"`
+  MinFrameSize uint32 `json:"minFrameSize"`
+  MaxFrameSize uint32 `json:"maxFrameSize"`
+  SampleRate uint32 `json:"sampleRate"`
+  Channels uint8 `json:"channels"`
+  BitsPerSample uint8 `json:"bitsPerSample"`
+  TotalSamples uint64 `json:"totalSamples"`
+  MD5 [16]byte `json:"md5"`
+}
+
+type FLACMetadataBlock struct {
+  Type uint8 `json:"type"`
+  Last bool `json:"last"`
+  Data []byte `json:"data"`
+  StreamInfo *FLACStreamInfo `json:"streamInfo"`
+}
+
+type FLACSpecificBox struct {
+  Box FullBox `json:"fullBox"`
+  Blocks []FLACMetadataBlock `json:"blocks"`
+}
+
+type AC3SpecificBox struct {
+  Box Box `json:"box"`
+  Fscod uint8 `json:"fscod"`
+  Bsid uint8 `json:"bsid"`
+  Bsmod uint8 `json:"bsmod"`
+  Acmod uint8 `json:"acmod"`
+  Lfeon bool `json:"lfeon"`
+  BitRateCode uint8 `json:"bitRateCode"`
+}
+
+type EC3Substream struct {
+  Fscod uint8 `json:"fscod"`
+  Bsid uint8 `json:"bsid"`
+  Asvc bool `json:"asvc"`
+  Bsmod uint8 `json:"bsmod"`
+  Acmod uint8 `json:"acmod"`
+  Lfeon bool `json:"lfeon"`
+  NumDepSub uint8 `json:"numDepSub"`
+  ChanLoc uint16 `json:"chanLoc"`
+}
+
+type EC3SpecificBox struct {
+  Box Box `json:"box"`
+  DataRate uint16 `json:"dataRate"`
+  Substreams []EC3Substream `json:"substreams"`
+  JOC bool `json:"joc"`
+  JOCComplexityIndex uint8 `json:"jocComplexityIndex"`
+}
+
+type AC4SpecificBox struct {
+  Box Box `json:"box"`
+  DSIVersion uint8 `json:"dsiVersion"`
+  BitstreamVersion uint8 `json:"bitstreamVersion"`
+  FsIndex uint8 `json:"fsIndex"`
+  FrameRateIndex uint8 `json:"frameRateIndex"`
+  NumPresentations uint16 `json:"numPresentations"`
+  PresentationVersion uint8 `json:"presentationVersion"`
+  MdCompat uint8 `json:"mdCompat"`
+  Data []byte `json:"data"`
+}
+
 type SoundSampleDescription struct {
+  // QuickTime sound description version, zero for ISO files
+  Version uint16 `json:"version"`
+  Revision uint16 `json:"revision"`
+  Vendor string `json:"vendor"`
   Channels uint16 `json:"channels"`
   SampleSize uint16 `json:"sampleSize"`
+  CompressionID int16 `json:"compressionId"`
+  PacketSize uint16 `json:"packetSize"`
   SampleRate float32 `json:"sampleRate"`
+  // version 1 only
+  SamplesPerPacket uint32 `json:"samplesPerPacket"`
+  BytesPerPacket uint32 `json:"bytesPerPacket"`
+  BytesPerFrame uint32 `json:"bytesPerFrame"`
+  BytesPerSample uint32 `json:"bytesPerSample"`
+  // version 2 only
+  FormatSpecificFlags uint32 `json:"formatSpecificFlags"`
+  ConstBytesPerAudioPacket uint32 `json:"constBytesPerAudioPacket"`
+  ConstLPCMFramesPerAudioPacket uint32 `json:"constLPCMFramesPerAudioPacket"`
+}
+
+type DecompressionParamBox struct {
+  Box Box `json:"box"`
+  // from frma
+  OriginalFormat string `json:"originalFormat"`
+  // from enda
+  LittleEndian bool `json:"littleEndian"`
+}
+
+type ChannelDescription struct {
+  Label uint32 `json:"label"`
+  Flags uint32 `json:"flags"`
+  Coordinates [3]float32 `json:"coordinates"`
+}
+
+type ChannelLayoutBox struct {
+  Box FullBox `json:"fullBox"`
+  LayoutTag uint32 `json:"layoutTag"`
+  Bitmap uint32 `json:"bitmap"`
+  Descriptions []ChannelDescription `json:"descriptions"`
+}
+
+type TimecodeSampleDescription struct {
+  Flags uint32 `json:"flags"`
+  Timescale uint32 `json:"timescale"`
+  FrameDuration uint32 `json:"frameDuration"`
+  NumberOfFrames uint8 `json:"numberOfFrames"`
+}
+
+type TextBoxRecord struct {
+  Top int16 `json:"top"`
+  Left int16 `json:"left"`
+  Bottom int16 `json:"bottom"`
+  Right int16 `json:"right"`
+}
+
+type TextStyleRecord struct {
+  StartChar uint16 `json:"startChar"`
+  EndChar uint16 `json:"endChar"`
+  FontID uint16 `json:"fontID"`
+  FaceStyleFlags uint8 `json:"faceStyleFlags"`
+  FontSize uint8 `json:"fontSize"`
+  TextColor [4]uint8 `json:"textColor"`
+}
+
+type TextSampleDescription struct {
+  DisplayFlags uint32 `json:"displayFlags"`
+  HorizontalJustification int8 `json:"horizontalJustification"`
+  VerticalJustification int8 `json:"verticalJustification"`
+  BackgroundColor [4]uint8 `json:"backgroundColor"`
+  DefaultTextBox TextBoxRecord `json:"defaultTextBox"`
+  DefaultStyle TextStyleRecord `json:"defaultStyle"`
+}
+
+type FontRecord struct {
+  FontID uint16 `json:"fontID"`
+  Name string `json:"name"`
+}
+
+type FontTableBox struct {
+  Box Box `json:"box"`
+  EntryCount uint16 `json:"entryCount"`
+  Fonts []FontRecord `json:"fonts"`
+}
+
+type WebVTTConfigBox struct {
+  Box Box `json:"box"`
+  Config string `json:"config"`
+}
+
+type WebVTTSourceLabelBox struct {
+  Box Box `json:"box"`
+  SourceLabel string `json:"sourceLabel"`
+}
+
+type XMLSubtitleSampleDescription struct {
+  Namespace string `json:"namespace"`
+  SchemaLocation string `json:"schemaLocation"`
+  AuxiliaryMimeTypes string `json:"auxiliaryMimeTypes"`
+}
+
+type TextMetadataSampleDescription struct {
+  ContentEncoding string `json:"contentEncoding"`
+  MimeFormat string `json:"mimeFormat"`
+}
+
+type XMLMetadataSampleDescription struct {
+  ContentEncoding string `json:"contentEncoding"`
+  Namespace string `json:"namespace"`
+  SchemaLocation string `json:"schemaLocation"`
+}
+
+type TextConfigBox struct {
+  Box FullBox `json:"fullBox"`
+  Config string `json:"config"`
+}
+
+type URIBox struct {
+  Box FullBox `json:"fullBox"`
+  URI string `json:"uri"`
+}
+
+type URIInitBox struct {
+  Box FullBox `json:"fullBox"`
+  Data []byte `json:"data"`
+}
+
+type OriginalFormatBox struct {
+  Box Box `json:"box"`
+  DataFormat string `json:"dataFormat"`
+}
+
+type SchemeTypeBox struct {
+  Box FullBox `json:"fullBox"`
+  SchemeType string `json:"schemeType"`
+  SchemeVersion uint32 `json:"schemeVersion"`
+  SchemeURI string `json:"schemeUri"`
+}
+
+type TrackEncryptionBox struct {
+  Box FullBox `json:"fullBox"`
+  // version 1 only, the pattern used by cens and cbcs
+  DefaultCryptByteBlock uint8 `json:"defaultCryptByteBlock"`
+  DefaultSkipByteBlock uint8 `json:"defaultSkipByteBlock"`
+  DefaultIsProtected uint8 `json:"defaultIsProtected"`
+  DefaultPerSampleIVSize uint8 `json:"defaultPerSampleIVSize"`
+  DefaultKID KID `json:"defaultKid"`
+  DefaultConstantIV []byte `json:"defaultConstantIV"`
+}
+
+type ProtectionSchemeInfoBox struct {
+  Box Box `json:"box"`
+  Frma OriginalFormatBox `json:"frma"`
+  Schm SchemeTypeBox `json:"schm"`
+  Tenc TrackEncryptionBox `json:"tenc"`
 }
 
 type SampleEntry struct {
@@ -163,6 +671,131 @@ type ChunkOffsetBox struct {
   ChunkOffset []uint32 `json:"chunkOffset"`
 }
 
+type ChunkLargeOffsetBox struct {
+  Box FullBox `json:"fullBox"`
+  EntryCount uint32 `json:"entryCount"`
+  ChunkOffset []uint64 `json:"chunkOffset"`
+}
+
+type SampleAuxInfoSizesBox struct {
+  Box FullBox `json:"fullBox"`
+  AuxInfoType string `json:"auxInfoType"`
+  AuxInfoTypeParameter uint32 `json:"auxInfoTypeParameter"`
+  DefaultSampleInfoSize uint8 `json:"defaultSampleInfoSize"`
+  SampleCount uint32 `json:"sampleCount"`
+  SampleInfoSize []uint8 `json:"sampleInfoSize"`
+}
+
+type SampleAuxInfoOffsetsBox struct {
+  Box FullBox `json:"fullBox"`
+  AuxInfoType string `json:"auxInfoType"`
+  AuxInfoTypeParameter uint32 `json:"auxInfoTypeParameter"`
+  EntryCount uint32 `json:"entryCount"`
+  Offsets []uint64 `json:"offsets"`
+}
+
+type SubsampleEntry struct {
+  BytesOfClearData uint16 `json:"bytesOfClearData"`
+  BytesOfProtectedData uint32 `json:"bytesOfProtectedData"`
+}
+
+type SampleEncryptionEntry struct {
+  IV []byte `json:"iv"`
+  Subsamples []SubsampleEntry `json:"subsamples"`
+}
+
+// SampleEncryptionBox keeps its entries undecoded since their layout
+// depends on the IV size signalled in tenc, see Entries.
+type SampleEncryptionBox struct {
+  Box FullBox `json:"fullBox"`
+  SampleCount uint32 `json:"sampleCount"`
+  data []byte
+}
+
+// SampleDependencyTypeBox holds one byte per sample packing is_leading,
+// sample_depends_on, sample_is_depended_on and sample_has_redundancy.
+type SampleDependencyTypeBox struct {
+  Box FullBox `json:"fullBox"`
+  Entries []uint8 `json:"entries"`
+}
+
+type SampleToGroupBox struct {
+  Box FullBox `json:"fullBox"`
+  GroupingType string `json:"groupingType"`
+  // version 1 only
+  GroupingTypeParameter uint32 `json:"groupingTypeParameter"`
+  EntryCount uint32 `json:"entryCount"`
+  SampleCount []uint32 `json:"sampleCount"`
+  // one based, zero meaning the sample is not in a group of this type
+  GroupDescriptionIndex []uint32 `json:"groupDescriptionIndex"`
+}
+
+// RollRecoveryEntry describes both roll and prol groups.
+type RollRecoveryEntry struct {
+  RollDistance int16 `json:"rollDistance"`
+}
+
+type VisualRandomAccessEntry struct {
+  NumLeadingSamplesKnown bool `json:"numLeadingSamplesKnown"`
+  NumLeadingSamples uint8 `json:"numLeadingSamples"`
+}
+
+type SyncSampleEntry struct {
+  NALUnitType uint8 `json:"nalUnitType"`
+}
+
+type TemporalLevelEntry struct {
+  LevelIndependentlyDecodable bool `json:"levelIndependentlyDecodable"`
+}
+
+type CencSampleEncryptionInfoEntry struct {
+  CryptByteBlock uint8 `json:"cryptByteBlock"`
+  SkipByteBlock uint8 `json:"skipByteBlock"`
+  IsProtected uint8 `json:"isProtected"`
+  PerSampleIVSize uint8 `json:"perSampleIVSize"`
+  KID KID `json:"kid"`
+  ConstantIV []byte `json:"constantIV"`
+}
+
+type SampleGroupDescriptionBox struct {
+  Box FullBox `json:"fullBox"`
+  GroupingType string `json:"groupingType"`
+  // version 1 only
+  DefaultLength uint32 `json:"defaultLength"`
+  // version 2 only
+  DefaultSampleDescriptionIndex uint32 `json:"defaultSampleDescriptionIndex"`
+  EntryCount uint32 `json:"entryCount"`
+  // one of the *Entry types above, or the raw bytes of unknown groupings
+  Entries []interface{} `json:"entries"`
+}
+
+type SubSampleInfo struct {
+  Size uint32 `json:"size"`
+  Priority uint8 `json:"priority"`
+  Discardable bool `json:"discardable"`
+  CodecSpecificParameters uint32 `json:"codecSpecificParameters"`
+}
+
+type SubSampleInfoEntry struct {
+  SampleDelta uint32 `json:"sampleDelta"`
+  Subsamples []SubSampleInfo `json:"subsamples"`
+}
+
+type SubSampleInformationBox struct {
+  Box FullBox `json:"fullBox"`
+  EntryCount uint32 `json:"entryCount"`
+  Entries []SubSampleInfoEntry `json:"entries"`
+}
+
+type CompositionToDecodeBox struct {
+  Box FullBox `json:"fullBox"`
+  CompositionToDTSShift int64 `json:"compositionToDtsShift"`
+  LeastDecodeToDisplayDelta int64 `json:"leastDecodeToDisplayDelta"`
+  GreatestDecodeToDisplayDelta int64 `json:"greatestDecodeToDisplayDelta"`
+  CompositionStartTime int64 `json:"compositionStartTime"`
+  CompositionEndTime int64 `json:"compositionEndTime"`
+}
+
 type SampleTableBox struct {
   Box Box `json:"box"`
   Stsd SampleDescriptionBox `json:"stsd"`
@@ -172,6 +805,17 @@ type SampleTableBox struct {
   Stsc SampleToChunkBox `json:"stsc"`
   Stsz SampleSizeBox `json:"stsz"`
   Stco ChunkOffsetBox `json:"stco"`
+  Co64 ChunkLargeOffsetBox `json:"co64"`
+  // encryption info of non fragmented files only, the moof/traf copies
+  // written by most packagers are not parsed
+  Saiz SampleAuxInfoSizesBox `json:"saiz"`
+  Saio SampleAuxInfoOffsetsBox `json:"saio"`
+  Senc SampleEncryptionBox `json:"senc"`
+  Sdtp SampleDependencyTypeBox `json:"sdtp"`
+  Sbgp []SampleToGroupBox `json:"sbgp"`
+  Sgpd []SampleGroupDescriptionBox `json:"sgpd"`
+  Subs SubSampleInformationBox `json:"subs"`
+  Cslg CompositionToDecodeBox `json:"cslg"`
 }
 
 type MediaInfoBox struct {
@@ -186,14 +830,38 @@ type MediaBox struct {
   Minf MediaInfoBox `json:"minf"`
 }
 
+type EditListBox struct {
+  Box FullBox `json:"fullBox"`
+  EntryCount uint32 `json:"entryCount"`
+  SegmentDuration []uint64 `json:"segmentDuration"`
+  MediaTime []int64 `json:"mediaTime"`
+  MediaRateInteger []int16 `json:"mediaRateInteger"`
+  MediaRateFraction []int16 `json:"mediaRateFraction"`
+}
+
+type EditBox struct {
+  Box Box `json:"box"`
+  Elst EditListBox `json:"elst"`
+}
+
 type TrackBox struct {
   Box Box `json:"box"`
   Tkhd TrackHeaderBox `json:"tkhd"`
+  Edts EditBox `json:"edts"`
   Mdia MediaBox `json:"mdia"`
 }
 
+type ProtectionSystemSpecificHeaderBox struct {
+  Box FullBox `json:"fullBox"`
+  SystemID SystemID `json:"systemId"`
+  // version 1 only
+  KIDs []KID `json:"kids"`
+  Data []byte `json:"data"`
+}
+
 type MovieBox struct {
   Box Box `json:"box"`
   Mvhd MovieHeaderBox `json:"mvhd"`
   Tracks []TrackBox `json:"tracks"`
+  Pssh []ProtectionSystemSpecificHeaderBox `json:"pssh"`
 }
@@ -0,0 +1,525 @@
+package mp4
+
+import (
+  "io"
+  "fmt"
+  "time"
+  "bytes"
+  "errors"
+  "strconv"
+  "strings"
+  "encoding/xml"
+  "encoding/binary"
+  "unicode/utf16"
+)
+
+const (
+  SUBTITLE_FORMAT_SRT = "srt"
+  SUBTITLE_FORMAT_WEBVTT = "webvtt"
+  SUBTITLE_FORMAT_TTML = "ttml"
+)
+
+// Cue is a single subtitle with its presentation interval.
+type Cue struct {
+  Start time.Duration
+  End time.Duration
+  ID string
+  Settings string
+  Text string
+}
+
+// Cues decodes the samples of a tx3g, wvtt, stpp or c608 track into
+// subtitle cues, timed on the presentation timeline.
+func (t *Track) Cues() ([]Cue, error) {
+  entry,err := t.SampleEntry();
+
+  if (err != nil) {
+    return nil, err;
+  }
+
+  var cues []Cue;
+  var cc *cea608Decoder;
+  // wvtt cues of the previous sample, which the next one may continue
+  var prevVTT []int;
+
+  if (entry.Box.Type == "c608") {
+    cc = newCEA608Decoder();
+  }
+
+  it := t.Samples(false);
+
+  for it.Next() {
+    s := it.Sample();
+    start := t.presentationTime(s.PTS);
+    end := t.presentationTime(s.PTS + int64(s.Duration));
+
+    switch (entry.Box.Type) {
+    case "tx3g":
+      text,err := decodeTX3GSample(s.Data);
+      if (err != nil) {
+        return nil, err;
+      }
+      // empty samples fill the gaps between cues
+      if (text != "") {
+        cues = append(cues, Cue{Start: start, End: end, Text: text});
+      }
+    case "wvtt":
+      vc,err := decodeWebVTTSample(s.Data);
+      if (err != nil) {
+        return nil, err;
+      }
+      var open []int;
+      for _,c := range vc {
+        c.Start = start;
+        c.End = end;
+        // a cue spanning several samples is repeated in each of them
+        i := continuedCue(cues, prevVTT, open, c);
+        if (i >= 0) {
+          cues[i].End = end;
+        } else {
+          i = len(cues);
+          cues = append(cues, c);
+        }
+        open = append(open, i);
+      }
+      prevVTT = open;
+    case "stpp":
+      tc,err := decodeTTMLSample(s.Data);
+      if (err != nil) {
+        return nil, err;
+      }
+      for _,c := range tc {
+        if (c.Start < 0) {
+          c.Start = start;
+          c.End = end;
+        } else {
+          c.Start = t.presentationTime(durationToUnits(c.Start, t.Timescale()));
+          if (c.End < 0) {
+            c.End = end;
+          } else {
+            c.End = t.presentationTime(durationToUnits(c.End, t.Timescale()));
+          }
+        }
+        cues = append(cues, c);
+      }
+    case "c608":
+      cues = cc.decode(s.Data, start, cues);
+    default:
+      return nil, fmt.Errorf("unsupported subtitle sample entry %q", entry.Box.Type);
+    }
+  }
+
+  if (it.Err() != nil) {
+    return nil, it.Err();
+  }
+
+  if (cc != nil) {
+    cues = cc.flush(t.presentationTime(int64(t.Box.Mdia.Mdhd.Duration)), cues);
+  }
+
+  return cues, nil;
+}
+
+// WriteSubtitles converts the track's cues to one of the SUBTITLE_FORMAT_*
+// text formats.
+func (t *Track) WriteSubtitles(w io.Writer, format string) error {
+  cues,err := t.Cues();
+
+  if (err != nil) {
+    return err;
+  }
+
+  switch (format) {
+  case SUBTITLE_FORMAT_SRT:
+    return WriteSRT(w, cues);
+  case SUBTITLE_FORMAT_WEBVTT:
+    header := "WEBVTT";
+    entry,_ := t.SampleEntry();
+    for _,ext := range entry.Extensions {
+      if vttc,ok := ext.(WebVTTConfigBox); ok && vttc.Config != "" {
+        header = vttc.Config;
+      }
+    }
+    return WriteWebVTT(w, header, cues);
+  case SUBTITLE_FORMAT_TTML:
+    return WriteTTML(w, t.Language(), cues);
+  }
+
+  return fmt.Errorf("unknown subtitle format %q", format);
+}
+
+func decodeTX3GSample(data []byte) (string, error) {
+  if (len(data) < 2) {
+    return "", errors.New("invalid tx3g sample");
+  }
+
+  n := int(binary.BigEndian.Uint16(data[0:2]));
+
+  if (len(data) < 2 + n) {
+    return "", errors.New("invalid tx3g sample");
+  }
+
+  text := data[2:2 + n];
+
+  // UTF-16 text is flagged by a byte order mark
+  if (len(text) >= 2 && text[0] == 0xfe && text[1] == 0xff) {
+    u := make([]uint16, (len(text) - 2) / 2);
+    for i := range u {
+      u[i] = binary.BigEndian.Uint16(text[2 + i * 2:]);
+    }
+    return string(utf16.Decode(u)), nil;
+  }
+
+  return string(text), nil;
+}
+
+// continuedCue returns the index of the cue among prev that c continues,
+// the same cue ending where c starts, or -1. Cues already continued by
+// the current sample, listed in taken, are skipped.
+func continuedCue(cues []Cue, prev []int, taken []int, c Cue) int {
+  for _,i := range prev {
+    p := &cues[i];
+    if (p.End != c.Start || p.Text != c.Text || p.ID != c.ID || p.Settings != c.Settings) {
+      continue;
+    }
+    used := false;
+    for _,j := range taken {
+      used = used || i == j;
+    }
+    if (!used) {
+      return i;
+    }
+  }
+  return -1;
+}
+
+func decodeWebVTTSample(data []byte) ([]Cue, error) {
+  var cues []Cue;
+  var perr error;
+
+  err := iterBoxes(data, func(b *Box, data []byte) {
+    if (b.Type != "vttc") {
+      return;
+    }
+    c := Cue{};
+    perr = iterBoxes(data, func(b *Box, data []byte) {
+      switch (b.Type) {
+      case "iden":
+        c.ID = string(data);
+      case "sttg":
+        c.Settings = string(data);
+      case "payl":
+        c.Text = string(data);
+      }
+    });
+    cues = append(cues, c);
+  });
+
+  if (err == nil) {
+    err = perr;
+  }
+
+  return cues, err;
+}
+
+// ttmlTiming holds the frame and tick rates time expressions are counted
+// in, set by the ttp parameters of the tt element.
+type ttmlTiming struct {
+  frameRate float64
+  tickRate float64
+}
+
+// parseTTMLTiming reads ttp:frameRate, ttp:subFrameRate,
+// ttp:frameRateMultiplier and ttp:tickRate, with the defaults of the TTML
+// specification: 30 frames per second and, without a frame rate, one tick
+// per second.
+func parseTTMLTiming(attrs []xml.Attr) (ttmlTiming, error) {
+  tm := ttmlTiming{frameRate: 30, tickRate: 1};
+  var frameRate,subFrameRate,multiplier,tickRate string;
+
+  for _,a := range attrs {
+    switch (a.Name.Local) {
+    case "frameRate":
+      frameRate = a.Value;
+    case "subFrameRate":
+      subFrameRate = a.Value;
+    case "frameRateMultiplier":
+      multiplier = a.Value;
+    case "tickRate":
+      tickRate = a.Value;
+    }
+  }
+
+  sub := 1.0;
+
+  if (subFrameRate != "") {
+    n,err := strconv.Atoi(subFrameRate);
+    if (err != nil || n <= 0) {
+      return tm, fmt.Errorf("invalid subFrameRate %q", subFrameRate);
+    }
+    sub = float64(n);
+  }
+
+  if (frameRate != "") {
+    n,err := strconv.Atoi(frameRate);
+    if (err != nil || n <= 0) {
+      return tm, fmt.Errorf("invalid frameRate %q", frameRate);
+    }
+    tm.frameRate = float64(n);
+    tm.tickRate = float64(n) * sub;
+  }
+
+  if (multiplier != "") {
+    f := strings.Fields(multiplier);
+    if (len(f) != 2) {
+      return tm, fmt.Errorf("invalid frameRateMultiplier %q", multiplier);
+    }
+    num,err1 := strconv.Atoi(f[0]);
+    den,err2 := strconv.Atoi(f[1]);
+    if (err1 != nil || err2 != nil || num <= 0 || den <= 0) {
+      return tm, fmt.Errorf("invalid frameRateMultiplier %q", multiplier);
+    }
+    tm.frameRate = tm.frameRate * float64(num) / float64(den);
+  }
+
+  if (tickRate != "") {
+    n,err := strconv.Atoi(tickRate);
+    if (err != nil || n <= 0) {
+      return tm, fmt.Errorf("invalid tickRate %q", tickRate);
+    }
+    tm.tickRate = float64(n);
+  }
+
+  return tm, nil;
+}
+
+// parseTTMLTime parses a TTML time expression, either clock time
+// (hh:mm:ss.fff or hh:mm:ss:ff) or an offset with a metric, frames and
+// ticks being counted at the rates of tm.
+func parseTTMLTime(v string, tm ttmlTiming) (time.Duration, error) {
+  if (strings.Contains(v, ":")) {
+    parts := strings.Split(v, ":");
+    if (len(parts) < 3) {
+      return 0, fmt.Errorf("invalid time expression %q", v);
+    }
+    h,err1 := strconv.Atoi(parts[0]);
+    m,err2 := strconv.Atoi(parts[1]);
+    s,err3 := strconv.ParseFloat(parts[2], 64);
+    if (err1 != nil || err2 != nil || err3 != nil) {
+      return 0, fmt.Errorf("invalid time expression %q", v);
+    }
+    d := time.Duration(h) * time.Hour + time.Duration(m) * time.Minute + time.Duration(s * float64(time.Second));
+    if (len(parts) > 3) {
+      f,err := strconv.ParseFloat(parts[3], 64);
+      if (err != nil) {
+        return 0, fmt.Errorf("invalid time expression %q", v);
+      }
+      d += time.Duration(f * float64(time.Second) / tm.frameRate);
+    }
+    return d, nil;
+  }
+
+  metrics := []struct{
+    suffix string
+    unit float64
+  }{
+    {"ms", float64(time.Millisecond)},
+    {"h", float64(time.Hour)},
+    {"m", float64(time.Minute)},
+    {"s", float64(time.Second)},
+    {"f", float64(time.Second) / tm.frameRate},
+    {"t", float64(time.Second) / tm.tickRate},
+  };
+
+  for _,mt := range metrics {
+    if (strings.HasSuffix(v, mt.suffix)) {
+      f,err := strconv.ParseFloat(strings.TrimSuffix(v, mt.suffix), 64);
+      if (err != nil) {
+        return 0, fmt.Errorf("invalid time expression %q", v);
+      }
+      return time.Duration(f * mt.unit), nil;
+    }
+  }
+
+  return 0, fmt.Errorf("invalid time expression %q", v);
+}
+
+// decodeTTMLSample extracts the p elements of a TTML document. Missing
+// begin or end times are left negative so the caller can use the sample
+// timing instead.
+func decodeTTMLSample(data []byte) ([]Cue, error) {
+  var cues []Cue;
+  var cur *Cue;
+  var text strings.Builder;
+
+  // begin offsets of the enclosing elements, children of a par container
+  // are timed relative to their parent
+  offsets := []time.Duration{0};
+  tm := ttmlTiming{frameRate: 30, tickRate: 1};
+
+  dec := xml.NewDecoder(bytes.NewReader(data));
+
+  for {
+    tok,err := dec.Token();
+
+    if (err == io.EOF) {
+      break;
+    }
+
+    if (err != nil) {
+      return nil, err;
+    }
+
+    switch v := tok.(type) {
+    case xml.StartElement:
+      if (v.Name.Local == "tt") {
+        tm,err = parseTTMLTiming(v.Attr);
+        if (err != nil) {
+          return nil, err;
+        }
+      }
+
+      var begin,end,dur string;
+      for _,a := range v.Attr {
+        switch (a.Name.Local) {
+        case "begin":
+          begin = a.Value;
+        case "end":
+          end = a.Value;
+        case "dur":
+          dur = a.Value;
+        }
+      }
+
+      off := offsets[len(offsets) - 1];
+
+      if (v.Name.Local == "p" && cur == nil) {
+        cur = &Cue{Start: -1, End: -1};
+        text.Reset();
+        if (begin != "") {
+          b,err := parseTTMLTime(begin, tm);
+          if (err != nil) {
+            return nil, err;
+          }
+          cur.Start = off + b;
+          if (end != "") {
+            e,err := parseTTMLTime(end, tm);
+            if (err != nil) {
+              return nil, err;
+            }
+            cur.End = off + e;
+          } else if (dur != "") {
+            d,err := parseTTMLTime(dur, tm);
+            if (err != nil) {
+              return nil, err;
+            }
+            cur.End = cur.Start + d;
+          }
+        }
+      } else if (v.Name.Local == "br" && cur != nil) {
+        text.WriteString("\n");
+      }
+
+      if (begin != "") {
+        b,err := parseTTMLTime(begin, tm);
+        if (err != nil) {
+          return nil, err;
+        }
+        off += b;
+      }
+
+      offsets = append(offsets, off);
+    case xml.EndElement:
+      offsets = offsets[:len(offsets) - 1];
+      if (v.Name.Local == "p" && cur != nil) {
+        cur.Text = strings.TrimSpace(text.String());
+        if (cur.Text != "") {
+          cues = append(cues, *cur);
+        }
+        cur = nil;
+      }
+    case xml.CharData:
+      if (cur != nil) {
+        text.Write(v);
+      }
+    }
+  }
+
+  return cues, nil;
+}
+
+func formatCueTime(d time.Duration, sep string) string {
+  ms := int64(d / time.Millisecond);
+  return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms / 3600000, (ms / 60000) % 60, (ms / 1000) % 60, sep, ms % 1000);
+}
+
+func WriteSRT(w io.Writer, cues []Cue) error {
+  for i,c := range cues {
+    _,err := fmt.Fprintf(w, "%d\n%s --> %s\n%s\n\n", i + 1,
+      formatCueTime(c.Start, ","), formatCueTime(c.End, ","), c.Text);
+    if (err != nil) {
+      return err;
+    }
+  }
+
+  return nil;
+}
+
+// WriteWebVTT writes the cues as a WebVTT file, header is the WEBVTT line
+// and anything following it up to the first cue.
+func WriteWebVTT(w io.Writer, header string, cues []Cue) error {
+  _,err := fmt.Fprintf(w, "%s\n\n", strings.TrimRight(header, "\n"));
+
+  if (err != nil) {
+    return err;
+  }
+
+  for _,c := range cues {
+    if (c.ID != "") {
+      _,err = fmt.Fprintf(w, "%s\n", c.ID);
+      if (err != nil) {
+        return err;
+      }
+    }
+    timing := formatCueTime(c.Start, ".") + " --> " + formatCueTime(c.End, ".");
+    if (c.Settings != "") {
+      timing += " " + c.Settings;
+    }
+    _,err = fmt.Fprintf(w, "%s\n%s\n\n", timing, c.Text);
+    if (err != nil) {
+      return err;
+    }
+  }
+
+  return nil;
+}
+
+func WriteTTML(w io.Writer, lang string, cues []Cue) error {
+  var buf bytes.Buffer;
+
+  if (lang == "") {
+    lang = "und";
+  }
+
+  buf.WriteString(xml.Header);
+  buf.WriteString(`<tt xmlns="http://www.w3.org/ns/ttml" xml:lang="`);
+  xml.EscapeText(&buf, []byte(lang));
+  buf.WriteString("\">\n  <body>\n    <div>\n");
+
+  for _,c := range cues {
+    fmt.Fprintf(&buf, "      <p begin=\"%s\" end=\"%s\">", formatCueTime(c.Start, "."), formatCueTime(c.End, "."));
+    for i,line := range strings.Split(c.Text, "\n") {
+      if (i > 0) {
+        buf.WriteString("<br/>");
+      }
+      xml.EscapeText(&buf, []byte(line));
+    }
+    buf.WriteString("</p>\n");
+  }
+
+  buf.WriteString("    </div>\n  </body>\n</tt>\n");
+
+  _,err := w.Write(buf.Bytes());
+
+  return err;
+}
@@ -0,0 +1,181 @@
+package mp4
+
+import (
+  "math"
+  "time"
+  "encoding/json"
+)
+
+// seconds between 1904-01-01 (the MP4 epoch) and 1970-01-01
+const MP4_EPOCH_OFFSET = 2082844800;
+
+// MP4Time converts seconds since 1904-01-01 UTC into a time.Time. Zero
+// means the value was never set and yields the zero time.Time.
+func MP4Time(secs uint64) time.Time {
+  if (secs == 0 || secs > math.MaxInt64) {
+    return time.Time{};
+  }
+  return time.Unix(int64(secs) - MP4_EPOCH_OFFSET, 0).UTC();
+}
+
+// TimescaleToDuration converts a duration expressed in timescale units into
+// a time.Duration. Durations with all bits set (unknown) or a zero
+// timescale yield zero and values that do not fit saturate.
+func TimescaleToDuration(units uint64, timescale uint32) time.Duration {
+  if (timescale == 0 || units == math.MaxUint64) {
+    return 0;
+  }
+
+  ts := uint64(timescale);
+  secs := units / ts;
+  rem := units % ts;
+
+  if (secs > uint64(math.MaxInt64 / int64(time.Second))) {
+    return time.Duration(math.MaxInt64);
+  }
+
+  d := time.Duration(secs) * time.Second;
+  frac := time.Duration(rem * uint64(time.Second) / ts);
+
+  if (d > time.Duration(math.MaxInt64) - frac) {
+    return time.Duration(math.MaxInt64);
+  }
+
+  return d + frac;
+}
+
+// headerDuration widens the duration of a header box, the 32 bit all ones
+// value of version 0 boxes meaning unknown just like the 64 bit one.
+func headerDuration(units uint64, version uint8) uint64 {
+  if (version == 0 && units == math.MaxUint32) {
+    return math.MaxUint64;
+  }
+  return units;
+}
+
+func (mhb MovieHeaderBox) CreationTime() time.Time {
+  return MP4Time(mhb.Ctime);
+}
+
+func (mhb MovieHeaderBox) ModificationTime() time.Time {
+  return MP4Time(mhb.Mtime);
+}
+
+func (mhb MovieHeaderBox) PlaybackDuration() time.Duration {
+  return TimescaleToDuration(headerDuration(mhb.Duration, mhb.Box.Version), mhb.Timescale);
+}
+
+func (thb TrackHeaderBox) CreationTime() time.Time {
+  return MP4Time(thb.Ctime);
+}
+
+func (thb TrackHeaderBox) ModificationTime() time.Time {
+  return MP4Time(thb.Mtime);
+}
+
+// PlaybackDuration needs the movie timescale (mvhd) since tkhd durations
+// are expressed in it.
+func (thb TrackHeaderBox) PlaybackDuration(movieTimescale uint32) time.Duration {
+  return TimescaleToDuration(headerDuration(thb.Duration, thb.Box.Version), movieTimescale);
+}
+
+func (mhb MediaHeaderBox) CreationTime() time.Time {
+  return MP4Time(mhb.Ctime);
+}
+
+func (mhb MediaHeaderBox) ModificationTime() time.Time {
+  return MP4Time(mhb.Mtime);
+}
+
+func (mhb MediaHeaderBox) PlaybackDuration() time.Duration {
+  return TimescaleToDuration(headerDuration(mhb.Duration, mhb.Box.Version), mhb.Timescale);
+}
+
+func jsonDate(t time.Time) *string {
+  if (t.IsZero()) {
+    return nil;
+  }
+  s := t.Format(time.RFC3339);
+  return &s;
+}
+
+// WithJSONTimes returns a copy of the boxes returned by Parse whose header
+// boxes also carry their creation and modification times as RFC 3339 dates
+// and, for mvhd and mdhd, their duration in seconds when marshalled to
+// JSON. The boxes passed in are left untouched.
+func WithJSONTimes(boxes []interface{}) []interface{} {
+  res := make([]interface{}, len(boxes));
+
+  for i,box := range boxes {
+    if mb,ok := box.(MovieBox); ok {
+      mb.Mvhd.jsonTimes = true;
+      mb.Tracks = append([]TrackBox(nil), mb.Tracks...);
+      for j := range mb.Tracks {
+        mb.Tracks[j].Tkhd.jsonTimes = true;
+        mb.Tracks[j].Mdia.Mdhd.jsonTimes = true;
+      }
+      box = mb;
+    }
+    res[i] = box;
+  }
+
+  return res;
+}
+
+func (mhb MovieHeaderBox) MarshalJSON() ([]byte, error) {
+  type plain MovieHeaderBox;
+
+  if (!mhb.jsonTimes) {
+    return json.Marshal(plain(mhb));
+  }
+
+  return json.Marshal(struct {
+    plain
+    CreationDate *string `json:"creationDate"`
+    ModificationDate *string `json:"modificationDate"`
+    DurationSeconds float64 `json:"durationSeconds"`
+  }{
+    plain(mhb),
+    jsonDate(mhb.CreationTime()),
+    jsonDate(mhb.ModificationTime()),
+    mhb.PlaybackDuration().Seconds(),
+  });
+}
+
+func (thb TrackHeaderBox) MarshalJSON() ([]byte, error) {
+  type plain TrackHeaderBox;
+
+  if (!thb.jsonTimes) {
+    return json.Marshal(plain(thb));
+  }
+
+  return json.Marshal(struct {
+    plain
+    CreationDate *string `json:"creationDate"`
+    ModificationDate *string `json:"modificationDate"`
+  }{
+    plain(thb),
+    jsonDate(thb.CreationTime()),
+    jsonDate(thb.ModificationTime()),
+  });
+}
+
+func (mhb MediaHeaderBox) MarshalJSON() ([]byte, error) {
+  type plain MediaHeaderBox;
+
+  if (!mhb.jsonTimes) {
+    return json.Marshal(plain(mhb));
+  }
+
+  return json.Marshal(struct {
+    plain
+    CreationDate *string `json:"creationDate"`
+    ModificationDate *string `json:"modificationDate"`
+    DurationSeconds float64 `json:"durationSeconds"`
+  }{
+    plain(mhb),
+    jsonDate(mhb.CreationTime()),
+    jsonDate(mhb.ModificationTime()),
+    mhb.PlaybackDuration().Seconds(),
+  });
+}
@@ -0,0 +1,165 @@
+package mp4
+
+import (
+  "io"
+  "time"
+  "errors"
+)
+
+const (
+  TRACK_KIND_UNKNOWN = "unknown"
+  TRACK_KIND_VIDEO = "video"
+  TRACK_KIND_AUDIO = "audio"
+  TRACK_KIND_SUBTITLE = "subtitle"
+  TRACK_KIND_METADATA = "metadata"
+  TRACK_KIND_TIMECODE = "timecode"
+)
+
+type Movie struct {
+  Box *MovieBox
+  Tracks []*Track
+}
+
+type Track struct {
+  Box *TrackBox
+  r io.ReaderAt
+  samples []sampleInfo
+  // sample indexes by presentation time, when ctts reorders them
+  presentationOrder []int
+  movieTimescale uint32
+  decrypter *sampleDecrypter
+  err error
+}
+
+func newMovie(mb *MovieBox, r io.ReaderAt) (*Movie, error) {
+  m := Movie{Box: mb};
+
+  for i := range mb.Tracks {
+    t := newTrack(&mb.Tracks[i], r);
+
+    t.movieTimescale = mb.Mvhd.Timescale;
+
+    m.Tracks = append(m.Tracks, t);
+  }
+
+  return &m, nil;
+}
+
+// newTrack keeps tracks whose sample index cannot be built so that the
+// rest of the movie stays usable, see Err.
+func newTrack(tb *TrackBox, r io.ReaderAt) *Track {
+  t := Track{Box: tb, r: r};
+
+  t.samples,t.err = buildSampleIndex(&tb.Mdia.Minf.Stbl);
+
+  if (t.err == nil && tb.Mdia.Minf.Stbl.Ctss.EntryCount > 0) {
+    t.presentationOrder = buildPresentationOrder(t.samples);
+  }
+
+  return &t;
+}
+
+// Err returns why the sample index of the track could not be built, such
+// tracks have no samples.
+func (t *Track) Err() error {
+  return t.err;
+}
+
+func (t *Track) ID() uint32 {
+  return t.Box.Tkhd.TrackID;
+}
+
+func (t *Track) SampleCount() int {
+  return len(t.samples);
+}
+
+func (t *Track) Kind() string {
+  switch t.Box.Mdia.Hdlr.HandlerType {
+  case "vide":
+    return TRACK_KIND_VIDEO;
+  case "soun":
+    return TRACK_KIND_AUDIO;
+  case "subt", "text", "sbtl", "clcp":
+    return TRACK_KIND_SUBTITLE;
+  case "meta":
+    return TRACK_KIND_METADATA;
+  case "tmcd":
+    return TRACK_KIND_TIMECODE;
+  }
+  return TRACK_KIND_UNKNOWN;
+}
+
+// SampleEntry returns the first sample description of the track, which is
+// the one used by the vast majority of files.
+func (t *Track) SampleEntry() (*SampleEntry, error) {
+  entries := t.Box.Mdia.Minf.Stbl.Stsd.Entries;
+
+  if (len(entries) == 0) {
+    return nil, errors.New("track has no sample entries");
+  }
+
+  return &entries[0], nil;
+}
+
+func (t *Track) Codec() string {
+  entry,err := t.SampleEntry();
+
+  if (err != nil) {
+    return "";
+  }
+
+  return CodecString(*entry);
+}
+
+func (t *Track) Timescale() uint32 {
+  return t.Box.Mdia.Mdhd.Timescale;
+}
+
+func (t *Track) Duration() time.Duration {
+  return t.Box.Mdia.Mdhd.PlaybackDuration();
+}
+
+func (t *Track) Language() string {
+  return t.Box.Mdia.Mdhd.Language;
+}
+
+// FrameRate returns the average number of samples per second, or zero for
+// non video tracks.
+func (t *Track) FrameRate() float64 {
+  if (t.Kind() != TRACK_KIND_VIDEO || t.Box.Mdia.Mdhd.Duration == 0) {
+    return 0;
+  }
+  return float64(len(t.samples)) * float64(t.Timescale()) / float64(t.Box.Mdia.Mdhd.Duration);
+}
+
+// Bitrate returns the average bitrate of the track in bits per second.
+func (t *Track) Bitrate() uint64 {
+  var total uint64;
+
+  for _,s := range t.samples {
+    total += uint64(s.size);
+  }
+
+  d := t.Duration().Seconds();
+
+  if (d == 0) {
+    return 0;
+  }
+
+  return uint64(float64(total * 8) / d);
+}
+
+// Dimensions returns the coded size from the visual sample entry, falling
+// back to the track header presentation size.
+func (t *Track) Dimensions() (int, int) {
+  entry,err := t.SampleEntry();
+
+  if (err == nil) {
+    vsd,ok := entry.SampleDesc.(VideoSampleDescription);
+    if (ok) {
+      return int(vsd.Width), int(vsd.Height);
+    }
+  }
+
+  return int(t.Box.Tkhd.Width), int(t.Box.Tkhd.Height);
+}
@@ -4,6 +4,7 @@ import (
   "fmt"
   "os"
   "log"
+  "flag"
 
   "encoding/json"
 
@@ -12,16 +13,20 @@ import (
 
 func printUsage() {
   fmt.Println("Usage:");
-  fmt.Println("  go run main.go input_file.mp4");
+  fmt.Println("  go run main.go [-times] input_file.mp4");
 }
 
 func main() {
-  if (len(os.Args) != 2) {
+  times := flag.Bool("times", false, "add human readable dates and durations to the output");
+  flag.Usage = printUsage;
+  flag.Parse();
+
+  if (flag.NArg() != 1) {
     printUsage();
     return;
   }
 
-  fname := os.Args[1];
+  fname := flag.Arg(0);
 
   f,err := os.OpenFile(fname, os.O_RDONLY, 0600);
 
@@ -35,7 +40,13 @@ func main() {
     log.Fatal(err);
   }
 
-  js,e := json.Marshal(res.Boxes);
+  boxes := res.Boxes;
+
+  if (*times) {
+    boxes = mp4.WithJSONTimes(boxes);
+  }
+
+  js,e := json.Marshal(boxes);
 
   if (e != nil) {
     fmt.Println(e);